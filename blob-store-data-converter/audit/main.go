@@ -0,0 +1,318 @@
+package main
+
+import (
+	bsdc "blob-store-data-converter"
+	"blob-store-data-converter/blobstore"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/api/enums/v1"
+	historypb "go.temporal.io/api/history/v1"
+	"go.temporal.io/api/proxy"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+)
+
+var workflowID string
+var runID string
+var dir string
+var redirectsPath string
+var mountsPath string
+
+func init() {
+	flag.StringVar(&workflowID, "w", "blobstore_codec", "Workflow ID to audit")
+	flag.StringVar(&runID, "r", "", "Run ID to audit, defaults to the latest run")
+	flag.StringVar(&dir, "dir", blobstore.DefaultDir, "Directory of the blob store")
+	flag.StringVar(&redirectsPath, "redirects", "", "Redirect map for blobs that have been migrated, see ./migrate")
+	flag.StringVar(&mountsPath, "mounts", "", "Mount table of blob prefixes migrated into other directories, see ./migrate")
+}
+
+const (
+	statusOK         = "ok"
+	statusMissing    = "MISSING"
+	statusUnreadable = "UNREADABLE"
+	statusExpired    = "expired"
+	statusOrphaned   = "orphaned"
+)
+
+// blobRef is a blobstore/plain payload found in the history
+type blobRef struct {
+	path      string
+	eventID   int64
+	eventType enums.EventType
+	size      int
+	status    string
+	err       error
+
+	expiresAt time.Time // zero when the blob never expires
+}
+
+// This walks the event history of a workflow execution and checks every blob it references.
+// Missing or unreadable blobs make the execution unreplayable, so the exit code is non-zero when any are found.
+// Blobs purged after their expiry, see ./sweeper, are reported as expired and don't fail the audit.
+//
+// Blobs under the workflow's prefix that no run of the workflow ID references are listed as orphaned.
+func main() {
+	flag.Parse()
+	os.Exit(run())
+}
+
+// run returns the exit code, so the deferred cleanup runs before exiting
+func run() int {
+	ctx := context.Background()
+
+	var redirects blobstore.Redirects
+	if redirectsPath != "" {
+		var err error
+		redirects, err = blobstore.LoadRedirects(redirectsPath)
+		if err != nil {
+			log.Println("Unable to load redirects", err)
+			return 1
+		}
+	}
+	var mounts blobstore.Mounts
+	if mountsPath != "" {
+		var err error
+		mounts, err = blobstore.LoadMounts(mountsPath)
+		if err != nil {
+			log.Println("Unable to load mounts", err)
+			return 1
+		}
+	}
+	bsClient := mounts.Store(blobstore.NewClientWithDir(dir))
+
+	// No DataConverter here, we want to see the raw payloads stored in the history
+	c, err := client.Dial(client.Options{})
+	if err != nil {
+		log.Println("Unable to create client", err)
+		return 1
+	}
+	defer c.Close()
+
+	events, err := getHistory(ctx, c, runID)
+	if err != nil {
+		log.Println("Unable to get workflow history", err)
+		return 1
+	}
+	if len(events) == 0 {
+		log.Println("Workflow history is empty", workflowID)
+		return 1
+	}
+
+	refs, err := findBlobRefs(ctx, events)
+	if err != nil {
+		log.Println("Unable to walk workflow history", err)
+		return 1
+	}
+
+	unhealthy, expired := 0, 0
+	for _, ref := range refs {
+		checkBlob(bsClient, redirects, ref, time.Now())
+		switch ref.status {
+		case statusOK:
+		case statusExpired:
+			expired++
+		default:
+			unhealthy++
+		}
+	}
+
+	// runs of the same workflow ID share the blob prefix, a blob is only orphaned when no run references it
+	referenced, err := findAllRunsBlobRefs(ctx, c)
+	if err != nil {
+		log.Println("Unable to walk the histories of the other runs", err)
+		return 1
+	}
+	for _, ref := range refs {
+		referenced[ref.path] = struct{}{}
+	}
+
+	orphans, err := findOrphans(bsClient, events[0], referenced)
+	if err != nil {
+		log.Println("Unable to list blobs", err)
+		return 1
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "EVENT\tTYPE\tSTATUS\tSIZE\tPATH\tERROR")
+	for _, ref := range append(refs, orphans...) {
+		errMsg := ""
+		if ref.err != nil {
+			errMsg = ref.err.Error()
+		}
+		eventID, eventType := "-", "-"
+		if ref.eventID != 0 {
+			eventID, eventType = fmt.Sprint(ref.eventID), ref.eventType.String()
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\n", eventID, eventType, ref.status, ref.size, ref.path, errMsg)
+	}
+	_ = tw.Flush()
+
+	fmt.Printf("\n%d blobs referenced, %d missing or unreadable, %d expired, %d orphaned\n", len(refs), unhealthy, expired, len(orphans))
+	if unhealthy > 0 {
+		fmt.Println("the execution is not replayable until the missing or unreadable blobs are restored")
+		return 1
+	}
+
+	return 0
+}
+
+// getHistory returns the events of a run of the workflow, the latest run when runID is empty
+func getHistory(ctx context.Context, c client.Client, runID string) ([]*historypb.HistoryEvent, error) {
+	var events []*historypb.HistoryEvent
+	iter := c.GetWorkflowHistory(ctx, workflowID, runID, false, enums.HISTORY_EVENT_FILTER_TYPE_ALL_EVENT)
+	for iter.HasNext() {
+		event, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// findAllRunsBlobRefs collects the blobs referenced by every run of the workflow ID still in visibility
+func findAllRunsBlobRefs(ctx context.Context, c client.Client) (map[string]struct{}, error) {
+	referenced := map[string]struct{}{}
+	var nextPageToken []byte
+	for {
+		resp, err := c.ListWorkflow(ctx, &workflowservice.ListWorkflowExecutionsRequest{
+			Query:         fmt.Sprintf("WorkflowId = '%s'", strings.ReplaceAll(workflowID, "'", "\\'")),
+			NextPageToken: nextPageToken,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, execution := range resp.GetExecutions() {
+			events, err := getHistory(ctx, c, execution.GetExecution().GetRunId())
+			if err != nil {
+				return nil, fmt.Errorf("run %s: %w", execution.GetExecution().GetRunId(), err)
+			}
+			refs, err := findBlobRefs(ctx, events)
+			if err != nil {
+				return nil, fmt.Errorf("run %s: %w", execution.GetExecution().GetRunId(), err)
+			}
+			for _, ref := range refs {
+				referenced[ref.path] = struct{}{}
+			}
+		}
+
+		nextPageToken = resp.GetNextPageToken()
+		if len(nextPageToken) == 0 {
+			return referenced, nil
+		}
+	}
+}
+
+// findBlobRefs visits every payload in the history, this covers inputs, results, signals, memos, headers, etc.
+func findBlobRefs(ctx context.Context, events []*historypb.HistoryEvent) ([]*blobRef, error) {
+	var refs []*blobRef
+	for _, event := range events {
+		err := proxy.VisitPayloads(ctx, event, proxy.VisitPayloadsOptions{
+			Visitor: func(_ *proxy.VisitPayloadsContext, payloads []*commonpb.Payload) ([]*commonpb.Payload, error) {
+				for _, p := range payloads {
+					if string(p.GetMetadata()["encoding"]) != bsdc.MetadataEncodingBlobStorePlain {
+						continue
+					}
+					expiresAt, _, err := bsdc.ClaimCheckExpiresAt(p)
+					if err != nil {
+						return nil, err
+					}
+					refs = append(refs, &blobRef{
+						path:      string(p.GetData()),
+						eventID:   event.GetEventId(),
+						eventType: event.GetEventType(),
+						expiresAt: expiresAt,
+					})
+				}
+				return payloads, nil
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return refs, nil
+}
+
+// checkBlob makes sure the blob exists and can be read back into a value, like BlobCodec.Decode and the
+// DataConverter would. Blobs have no checksum, so this doesn't detect every corruption, e.g. altered json.
+// A blob past its expiry may have been purged by the sweeper, it's reported as expired rather than missing.
+func checkBlob(c blobstore.Store, redirects blobstore.Redirects, ref *blobRef, now time.Time) {
+	data, err := c.GetBlob(redirects.Resolve(ref.path))
+	if errors.Is(err, os.ErrNotExist) && !ref.expiresAt.IsZero() && !now.Before(ref.expiresAt) {
+		ref.status, ref.err = statusExpired, fmt.Errorf("expired at %s", ref.expiresAt.Format(time.RFC3339))
+		return
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		ref.status, ref.err = statusMissing, err
+		return
+	}
+	if err != nil {
+		ref.status, ref.err = statusUnreadable, err
+		return
+	}
+
+	ref.size = len(data)
+	payload := &commonpb.Payload{}
+	if err := payload.Unmarshal(data); err != nil {
+		ref.status, ref.err = statusUnreadable, fmt.Errorf("not a payload: %w", err)
+		return
+	}
+	var value interface{}
+	if err := converter.GetDefaultDataConverter().FromPayload(payload, &value); err != nil {
+		ref.status, ref.err = statusUnreadable, err
+		return
+	}
+
+	ref.status = statusOK
+}
+
+// findOrphans lists blobs under the prefix the workflow ID uses for its own payloads, see bsdc.ExecutionBlobNamePrefix.
+// The prefix is shared by all runs of the workflow ID, so referenced must hold the blobs of every run.
+func findOrphans(c blobstore.Store, started *historypb.HistoryEvent, referenced map[string]struct{}) ([]*blobRef, error) {
+	attrs := started.GetWorkflowExecutionStartedEventAttributes()
+	if attrs == nil {
+		return nil, fmt.Errorf("first event is not WorkflowExecutionStarted: %s", started.GetEventType())
+	}
+
+	vals, err := bsdc.ExtractPropagatedValues(attrs.GetHeader())
+	if err != nil {
+		return nil, err
+	}
+
+	namePrefix := strings.Join(bsdc.ExecutionBlobNamePrefix(attrs.GetWorkflowType().GetName(), workflowID), "_")
+	keys, err := c.ListBlobs(fmt.Sprintf("%s/%s/%s__", bsdc.DefaultBucket, vals.TenantID, namePrefix))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+
+	var orphans []*blobRef
+	for _, key := range keys {
+		// the listed prefix also matches other workflow IDs, e.g. the blobs of "x_" are listed for "x"
+		bucket, tenant, pathPrefix, ok := bsdc.ParseBlobPath(key)
+		if !ok || bucket != bsdc.DefaultBucket || tenant != vals.TenantID || pathPrefix != namePrefix {
+			continue
+		}
+		if _, ok := referenced[key]; ok {
+			continue
+		}
+		orphans = append(orphans, &blobRef{path: key, status: statusOrphaned})
+	}
+
+	return orphans, nil
+}
@@ -0,0 +1,112 @@
+package blobref
+
+import (
+	bsdc "blob-store-data-converter"
+	"blob-store-data-converter/blobstore"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// Ref is a handle to a large object in the blob store.
+//
+// Unlike BlobCodec, which transparently offloads payloads and materializes them in memory again on decode,
+// a Ref is passed around as a small value and the bytes are only ever touched by activities through Open and Create.
+// Workflow code should never call Open or Create, they do I/O and are not deterministic.
+type Ref struct {
+	Path string `json:"path"`
+	Size int64  `json:"size,omitempty"`
+}
+
+// IsZero reports whether the Ref points at nothing
+func (r Ref) IsZero() bool {
+	return r.Path == ""
+}
+
+// Open streams the object behind the Ref from store, following the Redirects of options like BlobCodec does,
+// so a Ref still opens once its object was migrated. Pass the options of the BlobCodec.
+// Only objects of the tenant propagated in ctx can be opened, see NewContextPropagator.
+func (r Ref) Open(ctx context.Context, store blobstore.Store, options bsdc.BlobCodecOptions) (io.ReadCloser, error) {
+	if r.IsZero() {
+		return nil, fmt.Errorf("blobref: cannot open an empty ref")
+	}
+
+	vals, ok := ctx.Value(bsdc.PropagatedValuesKey).(bsdc.PropagatedValues)
+	if !ok {
+		vals = bsdc.UnknownTenant()
+	}
+	tenantPrefix := bucket(options) + "/" + vals.TenantID + "/"
+	rest := strings.TrimPrefix(r.Path, tenantPrefix)
+	if rest == r.Path || path.Clean("/"+rest) != "/"+rest {
+		return nil, fmt.Errorf("blobref: %q is not an object of tenant %q", r.Path, vals.TenantID)
+	}
+
+	return blobstore.OpenBlob(store, options.Redirects.Resolve(r.Path))
+}
+
+// bucket is the bucket of options, like BlobCodec defaults it
+func bucket(options bsdc.BlobCodecOptions) string {
+	if options.Bucket == "" {
+		return bsdc.DefaultBucket
+	}
+
+	return options.Bucket
+}
+
+// Writer streams a new object into the blob store, call Ref after Close to get the handle
+type Writer struct {
+	w        io.WriteCloser
+	ref      Ref
+	closed   bool
+	closeErr error
+}
+
+var _ io.WriteCloser = (*Writer)(nil) // ensure interface is implemented
+
+// Create starts a new object in store using the same bucket and tenant prefixing as BlobCodec with options.
+// The PropagatedValues are read from the activity context, see NewContextPropagator.
+func Create(ctx context.Context, store blobstore.Store, options bsdc.BlobCodecOptions) (*Writer, error) {
+	vals, ok := ctx.Value(bsdc.PropagatedValuesKey).(bsdc.PropagatedValues)
+	if !ok {
+		vals = bsdc.UnknownTenant()
+	}
+
+	path := bsdc.NewBlobPath(bucket(options), vals.TenantID, vals.BlobNamePrefix)
+	w, err := blobstore.CreateBlob(store, path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Writer{w: w, ref: Ref{Path: path}}, nil
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.ref.Size += int64(n)
+	return n, err
+}
+
+// Close finishes the upload, the object is not readable until then
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	w.closeErr = w.w.Close()
+	return w.closeErr
+}
+
+// Ref returns the handle to the object, it fails until Close succeeded
+func (w *Writer) Ref() (Ref, error) {
+	if !w.closed {
+		return Ref{}, errors.New("blobref: Ref called before Close")
+	}
+	if w.closeErr != nil {
+		return Ref{}, fmt.Errorf("blobref: upload of %q failed: %w", w.ref.Path, w.closeErr)
+	}
+
+	return w.ref, nil
+}
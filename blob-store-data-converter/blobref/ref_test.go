@@ -0,0 +1,141 @@
+package blobref
+
+import (
+	bsdc "blob-store-data-converter"
+	"blob-store-data-converter/blobstore"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/converter"
+)
+
+func Test_Ref(t *testing.T) {
+	client := blobstore.NewTestClient()
+	ctx := context.WithValue(context.Background(), bsdc.PropagatedValuesKey, bsdc.PropagatedValues{
+		TenantID:       "t1",
+		BlobNamePrefix: []string{t.Name()},
+	})
+
+	data := bytes.Repeat([]byte("large object "), 1024)
+
+	w, err := Create(ctx, client, bsdc.BlobCodecOptions{})
+	require.NoError(t, err)
+	_, err = io.Copy(w, bytes.NewReader(data))
+	require.NoError(t, err)
+	_, err = w.Ref()
+	require.Error(t, err, "the object isn't readable before Close")
+	require.NoError(t, w.Close())
+
+	ref, err := w.Ref()
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(ref.Path, bsdc.DefaultBucket+"/t1/"+t.Name()+"__"))
+	require.Equal(t, int64(len(data)), ref.Size)
+
+	// the ref is what gets passed through the workflow
+	p, err := converter.GetDefaultDataConverter().ToPayload(ref)
+	require.NoError(t, err)
+	var decoded Ref
+	require.NoError(t, converter.GetDefaultDataConverter().FromPayload(p, &decoded))
+	require.Equal(t, ref, decoded)
+
+	r, err := decoded.Open(ctx, client, bsdc.BlobCodecOptions{})
+	require.NoError(t, err)
+	defer r.Close()
+	got, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, data, got)
+
+	_, err = Ref{}.Open(ctx, client, bsdc.BlobCodecOptions{})
+	require.Error(t, err)
+}
+
+func Test_RefTenantIsolation(t *testing.T) {
+	client := blobstore.NewTestClient()
+	ctx := func(tenant string) context.Context {
+		return context.WithValue(context.Background(), bsdc.PropagatedValuesKey, bsdc.PropagatedValues{
+			TenantID:       tenant,
+			BlobNamePrefix: []string{t.Name()},
+		})
+	}
+
+	w, err := Create(ctx("t1"), client, bsdc.BlobCodecOptions{})
+	require.NoError(t, err)
+	_, err = w.Write([]byte("tenant 1 only"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	ref, err := w.Ref()
+	require.NoError(t, err)
+
+	r, err := ref.Open(ctx("t1"), client, bsdc.BlobCodecOptions{})
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+
+	_, err = ref.Open(ctx("t2"), client, bsdc.BlobCodecOptions{})
+	require.ErrorContains(t, err, `not an object of tenant "t2"`)
+
+	// no escaping the tenant prefix
+	_, err = Ref{Path: bsdc.DefaultBucket + "/t2/../t1/" + path.Base(ref.Path)}.Open(ctx("t2"), client, bsdc.BlobCodecOptions{})
+	require.Error(t, err)
+}
+
+// memStore is a Store that doesn't stream
+type memStore map[string][]byte
+
+func (s memStore) SaveBlob(key string, data []byte) error {
+	s[key] = data
+	return nil
+}
+
+func (s memStore) GetBlob(key string) ([]byte, error) {
+	data, ok := s[key]
+	if !ok {
+		return nil, fmt.Errorf("no blob %s", key)
+	}
+	return data, nil
+}
+
+func (s memStore) ListBlobs(prefix string) ([]string, error) {
+	var keys []string
+	for key := range s {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func Test_RefFollowsMigration(t *testing.T) {
+	old, migrated := memStore{}, memStore{}
+	ctx := context.WithValue(context.Background(), bsdc.PropagatedValuesKey, bsdc.PropagatedValues{
+		TenantID:       "t1",
+		BlobNamePrefix: []string{t.Name()},
+	})
+
+	w, err := Create(ctx, old, bsdc.BlobCodecOptions{Bucket: "blob://old"})
+	require.NoError(t, err)
+	_, err = w.Write([]byte("migrated object"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	ref, err := w.Ref()
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(ref.Path, "blob://old/t1/"))
+
+	opts := blobstore.MigrateOptions{SourcePrefix: "blob://old/", DestinationPrefix: "blob://new/"}
+	_, err = blobstore.Migrate(old, migrated, opts)
+	require.NoError(t, err)
+	delete(old, ref.Path)
+
+	// the Ref recorded in the history opens from where it was migrated to
+	r, err := ref.Open(ctx, migrated, bsdc.BlobCodecOptions{Bucket: "blob://old", Redirects: opts.Redirects()})
+	require.NoError(t, err)
+	got, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	require.Equal(t, "migrated object", string(got))
+}
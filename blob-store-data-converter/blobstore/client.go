@@ -1,20 +1,51 @@
 package blobstore
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 )
 
+// DefaultDir is where NewClient stores blobs on the local filesystem
+const DefaultDir = "/tmp/temporal-sample/blob-store-data-converter/blobs"
+
+// Store is the minimal set of operations BlobCodec and the migration tooling need from a blob store backend
+type Store interface {
+	SaveBlob(key string, data []byte) error
+	GetBlob(key string) ([]byte, error)
+	ListBlobs(prefix string) ([]string, error)
+}
+
+// MetadataStore is implemented by stores that keep metadata alongside each blob, like object storage does
+type MetadataStore interface {
+	Store
+	SaveBlobWithMetadata(key string, data []byte, metadata map[string]string) error
+	GetBlobMetadata(key string) (map[string]string, error)
+	DeleteBlob(key string) error
+}
+
+var _ MetadataStore = (*Client)(nil) // ensure interface is implemented
+
+// metadataSuffix is the sidecar file Client keeps blob metadata in
+const metadataSuffix = ".meta.json"
+
 type Client struct {
 	dir                    string
 	simulateNetworkLatency time.Duration
 }
 
 func NewClient() *Client {
+	return NewClientWithDir(DefaultDir)
+}
+
+// NewClientWithDir stores blobs in dir, e.g. to stand in for a second storage backend
+func NewClientWithDir(dir string) *Client {
 	return &Client{
-		dir:                    "/tmp/temporal-sample/blob-store-data-converter/blobs",
+		dir:                    dir,
 		simulateNetworkLatency: 1 * time.Second,
 	}
 }
@@ -32,7 +63,7 @@ func (b *Client) SaveBlob(key string, data []byte) error {
 		return fmt.Errorf("failed to create directory %s: %w", b.dir, err)
 	}
 
-	path := fmt.Sprintf(b.dir + "/" + strings.ReplaceAll(key, "/", "_"))
+	path := b.filePath(key)
 	fmt.Println("saving blob to: ", path)
 	err = os.WriteFile(path, data, 0644)
 	if err != nil {
@@ -44,8 +75,65 @@ func (b *Client) SaveBlob(key string, data []byte) error {
 	return nil
 }
 
+// SaveBlobWithMetadata saves the blob and a json sidecar file with its metadata
+func (b *Client) SaveBlobWithMetadata(key string, data []byte, metadata map[string]string) error {
+	err := os.MkdirAll(b.dir, 0755)
+	if err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", b.dir, err)
+	}
+
+	meta, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("failed to encode blob metadata: %w", err)
+	}
+	// written first, so a blob never exists without its metadata
+	err = os.WriteFile(b.filePath(key)+metadataSuffix, meta, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to save blob metadata: %w", err)
+	}
+
+	return b.SaveBlob(key, data)
+}
+
+// GetBlobMetadata returns the metadata saved with the blob, blobs saved without metadata return an empty map
+func (b *Client) GetBlobMetadata(key string) (map[string]string, error) {
+	metadata := map[string]string{}
+	meta, err := os.ReadFile(b.filePath(key) + metadataSuffix)
+	if os.IsNotExist(err) {
+		if _, err := os.Stat(b.filePath(key)); err != nil {
+			return nil, fmt.Errorf("failed to read blob: %w", err)
+		}
+		return metadata, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read blob metadata: %w", err)
+	}
+
+	if err := json.Unmarshal(meta, &metadata); err != nil {
+		return nil, fmt.Errorf("failed to decode blob metadata: %w", err)
+	}
+
+	return metadata, nil
+}
+
+// DeleteBlob removes the blob and its metadata
+func (b *Client) DeleteBlob(key string) error {
+	path := b.filePath(key)
+	fmt.Println("deleting blob: ", path)
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to delete blob: %w", err)
+	}
+	if err := os.Remove(path + metadataSuffix); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete blob metadata: %w", err)
+	}
+
+	time.Sleep(b.simulateNetworkLatency)
+
+	return nil
+}
+
 func (b *Client) GetBlob(key string) ([]byte, error) {
-	path := fmt.Sprintf(b.dir + "/" + strings.ReplaceAll(key, "/", "_"))
+	path := b.filePath(key)
 	fmt.Println("reading blob from: ", path)
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -56,3 +144,90 @@ func (b *Client) GetBlob(key string) ([]byte, error) {
 
 	return data, nil
 }
+
+// OpenBlob streams the blob instead of materializing it in memory like GetBlob
+func (b *Client) OpenBlob(key string) (io.ReadCloser, error) {
+	path := b.filePath(key)
+	fmt.Println("opening blob from: ", path)
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open blob: %w", err)
+	}
+
+	time.Sleep(b.simulateNetworkLatency)
+
+	return f, nil
+}
+
+// CreateBlob returns a writer for streaming a blob into the store.
+// The blob only becomes visible under key once the writer is closed successfully.
+func (b *Client) CreateBlob(key string) (io.WriteCloser, error) {
+	err := os.MkdirAll(b.dir, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create directory %s: %w", b.dir, err)
+	}
+
+	path := b.filePath(key)
+	fmt.Println("creating blob at: ", path)
+	f, err := os.CreateTemp(b.dir, ".upload-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create blob: %w", err)
+	}
+
+	return &blobWriter{File: f, path: path, latency: b.simulateNetworkLatency}, nil
+}
+
+// blobWriter writes to a temp file and moves it into place on Close
+type blobWriter struct {
+	*os.File
+	path    string
+	latency time.Duration
+}
+
+func (w *blobWriter) Close() error {
+	if err := w.File.Close(); err != nil {
+		_ = os.Remove(w.File.Name())
+		return fmt.Errorf("failed to save blob: %w", err)
+	}
+	if err := os.Rename(w.File.Name(), w.path); err != nil {
+		_ = os.Remove(w.File.Name())
+		return fmt.Errorf("failed to save blob: %w", err)
+	}
+
+	time.Sleep(w.latency)
+
+	return nil
+}
+
+// ListBlobs returns the keys of every blob that starts with prefix.
+//
+// Keys are flattened when saved, so any "/" after the prefix comes back as "_".
+// The returned keys still resolve to the same blobs.
+func (b *Client) ListBlobs(prefix string) ([]string, error) {
+	entries, err := os.ReadDir(b.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list blobs: %w", err)
+	}
+
+	flatPrefix := filepath.Base(b.filePath(prefix))
+	var keys []string
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, flatPrefix) || strings.HasSuffix(name, metadataSuffix) {
+			continue
+		}
+		keys = append(keys, prefix+strings.TrimPrefix(name, flatPrefix))
+	}
+
+	time.Sleep(b.simulateNetworkLatency)
+
+	return keys, nil
+}
+
+// filePath maps a blob key onto a flat file in the store's directory
+func (b *Client) filePath(key string) string {
+	return b.dir + "/" + strings.ReplaceAll(key, "/", "_")
+}
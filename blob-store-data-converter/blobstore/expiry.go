@@ -0,0 +1,70 @@
+package blobstore
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// MetadataExpiresAt is the blob metadata key holding the RFC 3339 time the blob must be purged at
+const MetadataExpiresAt = "expires-at"
+
+// ExpiresAt returns when the blob expires, ok is false if it never does
+func ExpiresAt(metadata map[string]string) (expiresAt time.Time, ok bool, err error) {
+	v, ok := metadata[MetadataExpiresAt]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+
+	expiresAt, err = time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid %s %q: %w", MetadataExpiresAt, v, err)
+	}
+
+	return expiresAt, true, nil
+}
+
+// Sweep deletes every blob under prefix that expired at or before now, no matter the state of the workflow.
+// It returns the keys that were deleted. A blob that fails doesn't stop the sweep, the errors of every failed
+// blob are joined. Blobs deleted while sweeping, e.g. by another sweeper, are skipped.
+func Sweep(store MetadataStore, prefix string, now time.Time) ([]string, error) {
+	keys, err := store.ListBlobs(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var deleted []string
+	var errs []error
+	for _, key := range keys {
+		metadata, err := store.GetBlobMetadata(key)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			continue
+		}
+
+		expiresAt, ok, err := ExpiresAt(metadata)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			continue
+		}
+		if !ok || expiresAt.After(now) {
+			continue
+		}
+
+		err = store.DeleteBlob(key)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			continue
+		}
+		deleted = append(deleted, key)
+	}
+
+	return deleted, errors.Join(errs...)
+}
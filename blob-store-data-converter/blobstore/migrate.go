@@ -0,0 +1,162 @@
+package blobstore
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// MigrateOptions configures Migrate
+type MigrateOptions struct {
+	// SourcePrefix selects the blobs to copy, e.g. a bucket "blob://mybucket/" or a tenant "blob://mybucket/tenant12/"
+	SourcePrefix string
+
+	// DestinationPrefix replaces SourcePrefix in the destination key. Defaults to SourcePrefix.
+	DestinationPrefix string
+
+	// JournalPath records every blob that was copied and verified.
+	// Re-running a migration with the same journal skips those blobs, so an interrupted migration can be resumed.
+	JournalPath string
+
+	// Logf is called for every blob, defaults to no logging
+	Logf func(format string, args ...interface{})
+}
+
+// MigrateResult summarizes a migration run
+type MigrateResult struct {
+	Copied  int
+	Skipped int
+}
+
+// Redirects returns the redirect map BlobCodec needs to find the migrated blobs from their old paths
+func (o MigrateOptions) Redirects() Redirects {
+	if o.DestinationPrefix == "" || o.DestinationPrefix == o.SourcePrefix {
+		return Redirects{}
+	}
+
+	return Redirects{o.SourcePrefix: o.DestinationPrefix}
+}
+
+// Migrate copies every blob under opts.SourcePrefix from src to dst.
+// Each copy is read back from dst and its sha256 digest compared against the source before it's journaled.
+func Migrate(src, dst Store, opts MigrateOptions) (MigrateResult, error) {
+	var result MigrateResult
+	if opts.SourcePrefix == "" {
+		return result, fmt.Errorf("migrate: a source prefix is required")
+	}
+	if opts.DestinationPrefix == "" {
+		opts.DestinationPrefix = opts.SourcePrefix
+	}
+	if opts.Logf == nil {
+		opts.Logf = func(string, ...interface{}) {}
+	}
+
+	done, err := readJournal(opts.JournalPath)
+	if err != nil {
+		return result, err
+	}
+
+	var journal *os.File
+	if opts.JournalPath != "" {
+		journal, err = os.OpenFile(opts.JournalPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return result, fmt.Errorf("migrate: failed to open journal: %w", err)
+		}
+		defer journal.Close()
+	}
+
+	keys, err := src.ListBlobs(opts.SourcePrefix)
+	if err != nil {
+		return result, err
+	}
+
+	for _, key := range keys {
+		if _, ok := done[key]; ok {
+			result.Skipped++
+			continue
+		}
+
+		dstKey := opts.DestinationPrefix + strings.TrimPrefix(key, opts.SourcePrefix)
+		digest, err := copyBlob(src, dst, key, dstKey)
+		if err != nil {
+			return result, err
+		}
+		opts.Logf("migrated %s -> %s sha256:%s\n", key, dstKey, digest)
+
+		if journal != nil {
+			if _, err := fmt.Fprintf(journal, "%s\t%s\n", key, digest); err != nil {
+				return result, fmt.Errorf("migrate: failed to write journal: %w", err)
+			}
+		}
+		result.Copied++
+	}
+
+	return result, nil
+}
+
+func copyBlob(src, dst Store, srcKey, dstKey string) (string, error) {
+	data, err := src.GetBlob(srcKey)
+	if err != nil {
+		return "", fmt.Errorf("migrate: %s: %w", srcKey, err)
+	}
+	want := sha256.Sum256(data)
+
+	// carry over metadata like the expiry when both stores support it
+	srcMeta, srcOK := src.(MetadataStore)
+	dstMeta, dstOK := dst.(MetadataStore)
+	if srcOK && dstOK {
+		metadata, err := srcMeta.GetBlobMetadata(srcKey)
+		if err != nil {
+			return "", fmt.Errorf("migrate: %s: %w", srcKey, err)
+		}
+		err = dstMeta.SaveBlobWithMetadata(dstKey, data, metadata)
+		if err != nil {
+			return "", fmt.Errorf("migrate: %s: %w", dstKey, err)
+		}
+	} else if err := dst.SaveBlob(dstKey, data); err != nil {
+		return "", fmt.Errorf("migrate: %s: %w", dstKey, err)
+	}
+
+	copied, err := dst.GetBlob(dstKey)
+	if err != nil {
+		return "", fmt.Errorf("migrate: failed to verify %s: %w", dstKey, err)
+	}
+	got := sha256.Sum256(copied)
+	if !bytes.Equal(want[:], got[:]) {
+		return "", fmt.Errorf("migrate: digest mismatch for %s: sha256:%x != sha256:%x", dstKey, got, want)
+	}
+
+	return hex.EncodeToString(want[:]), nil
+}
+
+// readJournal returns the source keys that have already been migrated
+func readJournal(path string) (map[string]struct{}, error) {
+	done := map[string]struct{}{}
+	if path == "" {
+		return done, nil
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return done, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("migrate: failed to read journal: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// a partially written last line is ignored, that blob is simply copied again
+		key, digest, ok := strings.Cut(scanner.Text(), "\t")
+		if ok && len(digest) == hex.EncodedLen(sha256.Size) {
+			done[key] = struct{}{}
+		}
+	}
+
+	return done, scanner.Err()
+}
@@ -0,0 +1,112 @@
+package blobstore
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newDirClient(t *testing.T) *Client {
+	return &Client{dir: t.TempDir()}
+}
+
+func Test_Migrate(t *testing.T) {
+	src, dst := newDirClient(t), newDirClient(t)
+	require.NoError(t, src.SaveBlob("blob://mybucket/t1/a", []byte("a")))
+	require.NoError(t, src.SaveBlob("blob://mybucket/t1/b", []byte("b")))
+	require.NoError(t, src.SaveBlob("blob://mybucket/t2/c", []byte("c")))
+
+	opts := MigrateOptions{
+		SourcePrefix:      "blob://mybucket/t1/",
+		DestinationPrefix: "blob://newbucket/t1/",
+		JournalPath:       filepath.Join(t.TempDir(), "journal"),
+	}
+
+	result, err := Migrate(src, dst, opts)
+	require.NoError(t, err)
+	require.Equal(t, MigrateResult{Copied: 2}, result)
+
+	// the old paths resolve to the migrated blobs
+	redirects := opts.Redirects()
+	data, err := dst.GetBlob(redirects.Resolve("blob://mybucket/t1/a"))
+	require.NoError(t, err)
+	require.Equal(t, "a", string(data))
+
+	_, err = dst.GetBlob(redirects.Resolve("blob://mybucket/t2/c"))
+	require.Error(t, err, "t2 is not part of the migration")
+
+	// resuming skips everything already journaled
+	require.NoError(t, src.SaveBlob("blob://mybucket/t1/d", []byte("d")))
+	result, err = Migrate(src, dst, opts)
+	require.NoError(t, err)
+	require.Equal(t, MigrateResult{Copied: 1, Skipped: 2}, result)
+}
+
+func Test_RedirectsResolve(t *testing.T) {
+	r := Redirects{
+		"blob://mybucket/":     "blob://newbucket/",
+		"blob://mybucket/t1/":  "s3://t1-bucket/",
+		"blob://otherbucket/x": "blob://y",
+	}
+
+	require.Equal(t, "blob://newbucket/t2/a", r.Resolve("blob://mybucket/t2/a"))
+	require.Equal(t, "s3://t1-bucket/a", r.Resolve("blob://mybucket/t1/a"), "longest prefix wins")
+	require.Equal(t, "blob://unknown/a", r.Resolve("blob://unknown/a"))
+	require.Equal(t, "blob://mybucket/a", Redirects(nil).Resolve("blob://mybucket/a"))
+}
+
+func Test_MountStore(t *testing.T) {
+	old, migrated := newDirClient(t), newDirClient(t)
+	require.NoError(t, old.SaveBlob("blob://mybucket/t1/a", []byte("a")))
+	require.NoError(t, old.SaveBlob("blob://mybucket/t2/b", []byte("b")))
+
+	// t1 is migrated into another store, t2 stays where it is
+	_, err := Migrate(old, migrated, MigrateOptions{SourcePrefix: "blob://mybucket/t1/"})
+	require.NoError(t, err)
+	store := NewMountStore(old, map[string]MetadataStore{"blob://mybucket/t1/": migrated})
+	require.NoError(t, old.DeleteBlob("blob://mybucket/t1/a"))
+
+	data, err := store.GetBlob("blob://mybucket/t1/a")
+	require.NoError(t, err)
+	require.Equal(t, "a", string(data))
+	data, err = store.GetBlob("blob://mybucket/t2/b")
+	require.NoError(t, err)
+	require.Equal(t, "b", string(data))
+
+	// new blobs of t1 go to the migrated store
+	require.NoError(t, store.SaveBlob("blob://mybucket/t1/c", []byte("c")))
+	_, err = old.GetBlob("blob://mybucket/t1/c")
+	require.Error(t, err)
+
+	keys, err := store.ListBlobs("blob://mybucket/")
+	require.NoError(t, err)
+	// Client flattens the part of the key after the listed prefix, the mounted store is listed from its mount
+	require.ElementsMatch(t, []string{"blob://mybucket/t1/a", "blob://mybucket/t1/c", "blob://mybucket/t2_b"}, keys)
+}
+
+// vanishingStore lists a blob that's deleted before it's read
+type vanishingStore struct {
+	*Client
+}
+
+func (s vanishingStore) ListBlobs(prefix string) ([]string, error) {
+	keys, err := s.Client.ListBlobs(prefix)
+	return append(keys, prefix+"vanished"), err
+}
+
+func Test_SweepContinuesPastErrors(t *testing.T) {
+	c := newDirClient(t)
+	now := time.Now()
+	require.NoError(t, c.SaveBlobWithMetadata("blob://mybucket/t1/bad", []byte("a"), map[string]string{MetadataExpiresAt: "soon"}))
+	require.NoError(t, c.SaveBlobWithMetadata("blob://mybucket/t1/expired", []byte("b"), map[string]string{
+		MetadataExpiresAt: now.Add(-time.Hour).Format(time.RFC3339),
+	}))
+	require.NoError(t, c.SaveBlob("blob://mybucket/t1/kept", []byte("c")))
+
+	deleted, err := Sweep(vanishingStore{c}, "blob://mybucket/t1/", now)
+	require.ErrorContains(t, err, "blob://mybucket/t1/bad")
+	require.NotContains(t, err.Error(), "vanished")
+	require.Equal(t, []string{"blob://mybucket/t1/expired"}, deleted)
+}
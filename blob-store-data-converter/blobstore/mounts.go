@@ -0,0 +1,155 @@
+package blobstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Mounts maps blob path prefixes to the directory of the Client storing them, e.g.
+//
+//	{"blob://mybucket/tenant12/": "/tmp/temporal-sample/blob-store-data-converter/migrated-blobs"}
+//
+// This serves a tenant migrated into another store next to the blobs that haven't moved, see MountStore.
+type Mounts map[string]string
+
+// LoadMounts reads a json mount table, a missing file is an empty table
+func LoadMounts(file string) (Mounts, error) {
+	b, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return Mounts{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mounts: %w", err)
+	}
+
+	m := Mounts{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse mounts %s: %w", file, err)
+	}
+
+	return m, nil
+}
+
+// Save writes the mount table as json
+func (m Mounts) Save(file string) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(file, b, 0644); err != nil {
+		return fmt.Errorf("failed to save mounts: %w", err)
+	}
+
+	return nil
+}
+
+// Store returns a MountStore with a Client for each mounted directory, other blobs are in store
+func (m Mounts) Store(store MetadataStore) *MountStore {
+	stores := make(map[string]MetadataStore, len(m))
+	clients := map[string]*Client{} // one per directory, so ListBlobs lists it once
+	for prefix, dir := range m {
+		if clients[dir] == nil {
+			clients[dir] = NewClientWithDir(dir)
+		}
+		stores[prefix] = clients[dir]
+	}
+
+	return NewMountStore(store, stores)
+}
+
+// MountStore routes each blob to the store mounted at its longest matching prefix, or to the default store
+type MountStore struct {
+	store  MetadataStore
+	mounts map[string]MetadataStore
+}
+
+var _ MetadataStore = (*MountStore)(nil) // ensure interface is implemented
+
+// NewMountStore returns a MountStore, blobs outside of every mount are in store
+func NewMountStore(store MetadataStore, mounts map[string]MetadataStore) *MountStore {
+	return &MountStore{store: store, mounts: mounts}
+}
+
+// route returns the store of key, the longest prefix wins
+func (s *MountStore) route(key string) MetadataStore {
+	from := ""
+	store := s.store
+	for prefix, mounted := range s.mounts {
+		if strings.HasPrefix(key, prefix) && len(prefix) > len(from) {
+			from, store = prefix, mounted
+		}
+	}
+
+	return store
+}
+
+func (s *MountStore) SaveBlob(key string, data []byte) error {
+	return s.route(key).SaveBlob(key, data)
+}
+
+func (s *MountStore) SaveBlobWithMetadata(key string, data []byte, metadata map[string]string) error {
+	return s.route(key).SaveBlobWithMetadata(key, data, metadata)
+}
+
+func (s *MountStore) GetBlob(key string) ([]byte, error) {
+	return s.route(key).GetBlob(key)
+}
+
+func (s *MountStore) GetBlobMetadata(key string) (map[string]string, error) {
+	return s.route(key).GetBlobMetadata(key)
+}
+
+func (s *MountStore) DeleteBlob(key string) error {
+	return s.route(key).DeleteBlob(key)
+}
+
+func (s *MountStore) OpenBlob(key string) (io.ReadCloser, error) {
+	return OpenBlob(s.route(key), key)
+}
+
+func (s *MountStore) CreateBlob(key string) (io.WriteCloser, error) {
+	return CreateBlob(s.route(key), key)
+}
+
+// ListBlobs lists prefix in every store it overlaps, each key is only returned by the store it routes to.
+// A store mounted under prefix is listed from its mount prefix, so the keys of a store that flattens them,
+// like Client, still route back to it.
+func (s *MountStore) ListBlobs(prefix string) ([]string, error) {
+	seen := map[string]bool{}
+	var keys []string
+	list := func(store MetadataStore, prefix string) error {
+		listed, err := store.ListBlobs(prefix)
+		if err != nil {
+			return err
+		}
+		for _, key := range listed {
+			if !seen[key] && s.route(key) == store {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+		return nil
+	}
+
+	if err := list(s.store, prefix); err != nil {
+		return nil, err
+	}
+	for mountPrefix, mounted := range s.mounts {
+		var err error
+		switch {
+		case strings.HasPrefix(mountPrefix, prefix):
+			err = list(mounted, mountPrefix)
+		case strings.HasPrefix(prefix, mountPrefix):
+			err = list(mounted, prefix)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,63 @@
+package blobstore
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Redirects maps old blob path prefixes to where those blobs live now, e.g.
+//
+//	{"blob://mybucket/": "blob://newbucket/"}
+//
+// Histories are immutable, so after blobs are migrated the old paths are still what's stored in the
+// workflow history. BlobCodec.Decode resolves each path through the Redirects before fetching it.
+type Redirects map[string]string
+
+// Resolve returns where path lives now, the longest matching prefix wins
+func (r Redirects) Resolve(path string) string {
+	from := ""
+	for prefix := range r {
+		if strings.HasPrefix(path, prefix) && len(prefix) > len(from) {
+			from = prefix
+		}
+	}
+	if from == "" {
+		return path
+	}
+
+	return r[from] + strings.TrimPrefix(path, from)
+}
+
+// LoadRedirects reads a json redirect map, a missing file is an empty map
+func LoadRedirects(file string) (Redirects, error) {
+	b, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return Redirects{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read redirects: %w", err)
+	}
+
+	r := Redirects{}
+	if err := json.Unmarshal(b, &r); err != nil {
+		return nil, fmt.Errorf("failed to parse redirects %s: %w", file, err)
+	}
+
+	return r, nil
+}
+
+// Save writes the redirect map as json
+func (r Redirects) Save(file string) error {
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(file, b, 0644); err != nil {
+		return fmt.Errorf("failed to save redirects: %w", err)
+	}
+
+	return nil
+}
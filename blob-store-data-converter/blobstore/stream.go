@@ -0,0 +1,50 @@
+package blobstore
+
+import (
+	"bytes"
+	"io"
+)
+
+// StreamStore is implemented by stores that stream blobs instead of holding them in memory, like Client
+type StreamStore interface {
+	Store
+	OpenBlob(key string) (io.ReadCloser, error)
+	CreateBlob(key string) (io.WriteCloser, error)
+}
+
+var _ StreamStore = (*Client)(nil)     // ensure interface is implemented
+var _ StreamStore = (*MountStore)(nil) // ensure interface is implemented
+
+// OpenBlob streams the blob from a StreamStore, other stores read it into memory first
+func OpenBlob(s Store, key string) (io.ReadCloser, error) {
+	if streamer, ok := s.(StreamStore); ok {
+		return streamer.OpenBlob(key)
+	}
+
+	data, err := s.GetBlob(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// CreateBlob streams a new blob into a StreamStore, other stores get it saved in one piece on Close
+func CreateBlob(s Store, key string) (io.WriteCloser, error) {
+	if streamer, ok := s.(StreamStore); ok {
+		return streamer.CreateBlob(key)
+	}
+
+	return &bufferedWriter{store: s, key: key}, nil
+}
+
+// bufferedWriter collects a blob in memory and saves it on Close
+type bufferedWriter struct {
+	bytes.Buffer
+	store Store
+	key   string
+}
+
+func (w *bufferedWriter) Close() error {
+	return w.store.SaveBlob(w.key, w.Bytes())
+}
@@ -5,54 +5,132 @@ import (
 	"blob-store-data-converter/blobstore"
 	"flag"
 	"fmt"
+	commonpb "go.temporal.io/api/common/v1"
 	"go.temporal.io/sdk/converter"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
 	"strconv"
+	"syscall"
+	"tlsreload"
 )
 
 var portFlag int
 var web string
+var dir string
+var redirectsPath string
+var mountsPath string
+var tlsCert string
+var tlsKey string
+var tlsClientCA string
 
 func init() {
 	flag.IntVar(&portFlag, "port", 8082, "Port to listen on")
 	flag.StringVar(&web, "web", "http://localhost:8233", "Temporal UI URL")
+	flag.StringVar(&dir, "dir", blobstore.DefaultDir, "Directory of the blob store")
+	flag.StringVar(&redirectsPath, "redirects", "", "Redirect map for blobs that have been migrated, see ./migrate")
+	flag.StringVar(&mountsPath, "mounts", "", "Mount table of blob prefixes migrated into other directories, see ./migrate")
+	flag.StringVar(&tlsCert, "tls-cert", "", "Certificate file to serve HTTPS with, reloaded on SIGHUP")
+	flag.StringVar(&tlsKey, "tls-key", "", "Private key file of -tls-cert")
+	flag.StringVar(&tlsClientCA, "tls-client-ca", "", "CA bundle to verify client certificates with, enables mTLS")
 }
 
 func main() {
 	flag.Parse()
 
+	var redirects blobstore.Redirects
+	if redirectsPath != "" {
+		var err error
+		redirects, err = blobstore.LoadRedirects(redirectsPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	var mounts blobstore.Mounts
+	if mountsPath != "" {
+		var err error
+		mounts, err = blobstore.LoadMounts(mountsPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	// This example codec server does not support varying config per namespace,
 	// decoding for the Temporal Web UI or oauth.
 	// For a more complete example of a codec server please see the codec-server sample at:
 	// https://github.com/temporalio/samples-go/tree/main/codec-server
-	handler := converter.NewPayloadCodecHTTPHandler(
+	// payloads are decoded one by one, so a missing blob doesn't hide the rest of the history
+	failsafe := bsdc.NewFailsafeCodec(
 		//bsdc.NewBaseCodec(blobstore.NewClient()),
-		bsdc.NewBlobCodec(blobstore.NewClient(), bsdc.PropagatedValues{}),
+		bsdc.NewBlobCodecWithOptions(
+			mounts.Store(blobstore.NewClientWithDir(dir)),
+			bsdc.PropagatedValues{},
+			bsdc.BlobCodecOptions{
+				Redirects: redirects,
+				// show a placeholder for expired blobs so the rest of the history is still visible
+				RedactExpired: true,
+			},
+		),
 	)
+	failsafe.OnError = func(p *commonpb.Payload, err error) {
+		log.Printf("failed to decode payload: %v", err)
+	}
+	handler := converter.NewPayloadCodecHTTPHandler(failsafe)
 
 	srv := &http.Server{
 		Addr:    "localhost:" + strconv.Itoa(portFlag),
 		Handler: newCORSHTTPHandler(handler),
 	}
 
+	var reloader *tlsreload.Reloader
+	if tlsCert != "" || tlsKey != "" || tlsClientCA != "" {
+		if tlsCert == "" || tlsKey == "" {
+			log.Fatal("-tls-cert and -tls-key are both required to serve HTTPS")
+		}
+		var err error
+		reloader, err = tlsreload.New(tlsCert, tlsKey, tlsClientCA)
+		if err != nil {
+			log.Fatal(err)
+		}
+		srv.TLSConfig = reloader.TLSConfig()
+	}
+
 	errCh := make(chan error, 1)
 	go func() {
 		fmt.Printf("allowing CORS Headers for %s\n", web)
-		fmt.Printf("Listening on http://%s/\n", srv.Addr)
-		errCh <- srv.ListenAndServe()
+		if reloader != nil {
+			fmt.Printf("Listening on https://%s/\n", srv.Addr)
+			errCh <- srv.ListenAndServeTLS("", "")
+		} else {
+			fmt.Printf("Listening on http://%s/\n", srv.Addr)
+			errCh <- srv.ListenAndServe()
+		}
 	}()
 
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, os.Interrupt)
+	// without TLS there's nothing to reload, SIGHUP keeps its default behavior
+	var hupCh chan os.Signal
+	if reloader != nil {
+		hupCh = make(chan os.Signal, 1)
+		signal.Notify(hupCh, syscall.SIGHUP)
+	}
 
-	select {
-	case <-sigCh:
-		_ = srv.Close()
-	case err := <-errCh:
-		log.Fatal(err)
+	for {
+		select {
+		case <-hupCh:
+			if err := reloader.Reload(); err != nil {
+				log.Println("Failed to reload TLS certificates, keeping the current ones:", err)
+			} else {
+				log.Println("Reloaded TLS certificates")
+			}
+		case <-sigCh:
+			_ = srv.Close()
+			return
+		case err := <-errCh:
+			log.Fatal(err)
+		}
 	}
 }
 
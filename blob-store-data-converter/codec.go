@@ -2,11 +2,13 @@ package blobstore_data_converter
 
 import (
 	"blob-store-data-converter/blobstore"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	commonpb "go.temporal.io/api/common/v1"
 	"go.temporal.io/sdk/converter"
 	"strings"
+	"time"
 )
 
 const (
@@ -17,26 +19,68 @@ const (
 	//
 	// For this example, as a proof of concept, we'll use much smaller size limit.
 	payloadSizeLimit = 37
+
+	// DefaultBucket is where BlobCodec stores blobs
+	DefaultBucket = "blob://mybucket"
 )
 
+// ErrCorruptBlob is returned by BlobCodec.Decode for a blob that isn't a payload
+var ErrCorruptBlob = errors.New("blob is not a payload")
+
 // BlobCodec knows where to store the blobs from the PropagatedValues
 // Note, see readme for details on missing values
 type BlobCodec struct {
-	client     *blobstore.Client
-	bucket     string
-	tenant     string
-	pathPrefix []string
+	client        blobstore.Store
+	bucket        string
+	tenant        string
+	pathPrefix    []string
+	redirects     blobstore.Redirects
+	ttl           time.Duration
+	redactExpired bool
+	now           func() time.Time
 }
 
 var _ = converter.PayloadCodec(&BlobCodec{}) // Ensure that BlobCodec implements converter.PayloadCodec
 
+// BlobCodecOptions configures where BlobCodec stores and finds blobs
+type BlobCodecOptions struct {
+	// Bucket new blobs are stored under, defaults to DefaultBucket
+	Bucket string
+
+	// Redirects lets Decode find blobs that were migrated away from the path stored in the history.
+	// See blobstore.Migrate.
+	Redirects blobstore.Redirects
+
+	// Retention stamps an expiry on new blobs, into both the blob metadata and the claim-check.
+	// Expired blobs are deleted by blobstore.Sweep, see ./sweeper.
+	Retention RetentionPolicy
+
+	// RedactExpired makes Decode return an ExpiredPayload placeholder instead of an ExpiredPayloadError.
+	// This is meant for the codec server, so the rest of the history can still be displayed.
+	RedactExpired bool
+}
+
 // NewBlobCodec is aware of where of the propagated context values from the data converter
-func NewBlobCodec(c *blobstore.Client, values PropagatedValues) *BlobCodec {
+func NewBlobCodec(c blobstore.Store, values PropagatedValues) *BlobCodec {
+	return NewBlobCodecWithOptions(c, values, BlobCodecOptions{})
+}
+
+// NewBlobCodecWithOptions is NewBlobCodec configured with BlobCodecOptions
+func NewBlobCodecWithOptions(c blobstore.Store, values PropagatedValues, options BlobCodecOptions) *BlobCodec {
+	bucket := options.Bucket
+	if bucket == "" {
+		bucket = DefaultBucket
+	}
+
 	return &BlobCodec{
-		client:     c,
-		bucket:     "blob://mybucket",
-		tenant:     values.TenantID,
-		pathPrefix: values.BlobNamePrefix,
+		client:        c,
+		bucket:        bucket,
+		tenant:        values.TenantID,
+		pathPrefix:    values.BlobNamePrefix,
+		redirects:     options.Redirects,
+		ttl:           options.Retention.TTL(values.TenantID),
+		redactExpired: options.RedactExpired,
+		now:           time.Now,
 	}
 }
 
@@ -56,25 +100,70 @@ func (c *BlobCodec) Encode(payloads []*commonpb.Payload) ([]*commonpb.Payload, e
 			return payloads, err
 		}
 
+		claimCheck := &commonpb.Payload{
+			Metadata: map[string][]byte{
+				"encoding": []byte(MetadataEncodingBlobStorePlain),
+			},
+		}
+
 		// save the data in our blob store db
-		objectName := strings.Join(c.pathPrefix, "_") + "__" + uuid.New().String() // ensures each blob is unique
-		path := fmt.Sprintf("%s/%s/%s", c.bucket, c.tenant, objectName)
-		err = c.client.SaveBlob(path, origBytes)
+		path := NewBlobPath(c.bucket, c.tenant, c.pathPrefix)
+		if c.ttl > 0 {
+			expiresAt := c.now().Add(c.ttl).UTC().Format(time.RFC3339)
+			claimCheck.Metadata[MetadataBlobExpiresAt] = []byte(expiresAt)
+
+			store, ok := c.client.(blobstore.MetadataStore)
+			if !ok {
+				return payloads, fmt.Errorf("blob store %T does not support metadata, required for the retention policy", c.client)
+			}
+			err = store.SaveBlobWithMetadata(path, origBytes, map[string]string{
+				blobstore.MetadataExpiresAt: expiresAt,
+			})
+		} else {
+			err = c.client.SaveBlob(path, origBytes)
+		}
 		if err != nil {
 			return payloads, err
 		}
 
-		result[i] = &commonpb.Payload{
-			Metadata: map[string][]byte{
-				"encoding": []byte(MetadataEncodingBlobStorePlain),
-			},
-			Data: []byte(path),
-		}
+		claimCheck.Data = []byte(path)
+		result[i] = claimCheck
 	}
 
 	return result, nil
 }
 
+// NewBlobPath returns a unique object path for the tenant, this is the layout used by BlobCodec
+func NewBlobPath(bucket, tenant string, pathPrefix []string) string {
+	objectName := strings.Join(pathPrefix, "_") + "__" + uuid.New().String() // ensures each blob is unique
+	return fmt.Sprintf("%s/%s/%s", bucket, tenant, objectName)
+}
+
+// ParseBlobPath splits a path made by NewBlobPath into its bucket, tenant and joined pathPrefix.
+// ok is false for any other path, e.g. one whose object name doesn't end in "__" and a UUID.
+func ParseBlobPath(path string) (bucket, tenant, pathPrefix string, ok bool) {
+	i := strings.LastIndex(path, "/")
+	if i < 0 {
+		return "", "", "", false
+	}
+	rest, objectName := path[:i], path[i+1:]
+	if i = strings.LastIndex(rest, "/"); i < 0 {
+		return "", "", "", false
+	}
+	bucket, tenant = rest[:i], rest[i+1:]
+
+	i = strings.LastIndex(objectName, "__")
+	if i < 0 {
+		return "", "", "", false
+	}
+	id, err := uuid.Parse(objectName[i+2:])
+	if err != nil || id.String() != objectName[i+2:] {
+		return "", "", "", false
+	}
+
+	return bucket, tenant, objectName[:i], true
+}
+
 // Decode does not need to be context aware because it can fetch the blobs via the payload path
 func (c *BlobCodec) Decode(payloads []*commonpb.Payload) ([]*commonpb.Payload, error) {
 	result := make([]*commonpb.Payload, len(payloads))
@@ -84,8 +173,25 @@ func (c *BlobCodec) Decode(payloads []*commonpb.Payload) ([]*commonpb.Payload, e
 			continue
 		}
 
-		// fetch it from our blob store db
-		data, err := c.client.GetBlob(string(p.Data))
+		// the blob may already be purged, don't report that as a generic missing blob
+		expiresAt, expires, err := ClaimCheckExpiresAt(p)
+		if err != nil {
+			return payloads, err
+		}
+		if expires && !c.now().Before(expiresAt) {
+			expiredErr := &ExpiredPayloadError{Path: string(p.Data), ExpiredAt: expiresAt}
+			if !c.redactExpired {
+				return payloads, expiredErr
+			}
+			result[i], err = expiredErr.payload()
+			if err != nil {
+				return payloads, err
+			}
+			continue
+		}
+
+		// fetch it from our blob store db, following any redirects from a migration
+		data, err := c.client.GetBlob(c.redirects.Resolve(string(p.Data)))
 		if err != nil {
 			return payloads, err
 		}
@@ -93,7 +199,7 @@ func (c *BlobCodec) Decode(payloads []*commonpb.Payload) ([]*commonpb.Payload, e
 		result[i] = &commonpb.Payload{}
 		err = result[i].Unmarshal(data)
 		if err != nil {
-			return payloads, err
+			return payloads, fmt.Errorf("%w: %s: %v", ErrCorruptBlob, string(p.Data), err)
 		}
 	}
 
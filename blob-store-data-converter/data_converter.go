@@ -8,7 +8,8 @@ import (
 )
 
 type DataConverter struct {
-	client *blobstore.Client
+	client  blobstore.Store
+	options BlobCodecOptions
 
 	parent converter.DataConverter // Until EncodingDataConverter supports workflow.ContextAware we'll store parent here.
 
@@ -18,13 +19,19 @@ type DataConverter struct {
 var _ = workflow.ContextAware(&DataConverter{}) // Ensure that DataConverter implements workflow.ContextAware
 
 // NewDataConverter returns DataConverter, which embeds converter.DataConverter
-func NewDataConverter(parent converter.DataConverter, client *blobstore.Client) *DataConverter {
+func NewDataConverter(parent converter.DataConverter, client blobstore.Store) *DataConverter {
+	return NewDataConverterWithOptions(parent, client, BlobCodecOptions{})
+}
+
+// NewDataConverterWithOptions is NewDataConverter where every BlobCodec is created with options
+func NewDataConverterWithOptions(parent converter.DataConverter, client blobstore.Store, options BlobCodecOptions) *DataConverter {
 	next := []converter.PayloadCodec{
-		NewBlobCodec(client, UnknownTenant()),
+		NewBlobCodecWithOptions(client, UnknownTenant(), options),
 	}
 
 	return &DataConverter{
 		client:        client,
+		options:       options,
 		parent:        parent,
 		DataConverter: converter.NewCodecDataConverter(parent, next...),
 	}
@@ -42,7 +49,7 @@ func (dc *DataConverter) WithContext(ctx context.Context) converter.DataConverte
 			parent = parentWithContext.WithContext(ctx)
 		}
 
-		return converter.NewCodecDataConverter(parent, NewBlobCodec(dc.client, vals))
+		return converter.NewCodecDataConverter(parent, NewBlobCodecWithOptions(dc.client, vals, dc.options))
 	}
 
 	return dc
@@ -58,7 +65,7 @@ func (dc *DataConverter) WithWorkflowContext(ctx workflow.Context) converter.Dat
 			parent = parentWithContext.WithWorkflowContext(ctx)
 		}
 
-		return converter.NewCodecDataConverter(parent, NewBlobCodec(dc.client, vals))
+		return converter.NewCodecDataConverter(parent, NewBlobCodecWithOptions(dc.client, vals, dc.options))
 	}
 
 	return dc
@@ -3,9 +3,12 @@ package blobstore_data_converter
 import (
 	"blob-store-data-converter/blobstore"
 	"context"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+	commonpb "go.temporal.io/api/common/v1"
 	"go.temporal.io/sdk/converter"
 )
 
@@ -41,3 +44,69 @@ func Test_DataConverter(t *testing.T) {
 
 	require.Equal(t, largePayload, result)
 }
+
+func Test_ParseBlobPath(t *testing.T) {
+	path := NewBlobPath(DefaultBucket, "t1", []string{"wf", "x_"})
+	bucket, tenant, pathPrefix, ok := ParseBlobPath(path)
+	require.True(t, ok)
+	require.Equal(t, []string{DefaultBucket, "t1", "wf_x_"}, []string{bucket, tenant, pathPrefix})
+
+	for _, path := range []string{
+		"",
+		DefaultBucket + "/t1/wf_x__not-a-uuid",
+		DefaultBucket + "/t1/wf_x",
+		"wf_x__" + "0b4f7f4e-6f3a-4c43-9a5b-3f1f0c2d6e7a",
+		DefaultBucket + "/t1/wf_x__{0b4f7f4e-6f3a-4c43-9a5b-3f1f0c2d6e7a}",
+	} {
+		_, _, _, ok := ParseBlobPath(path)
+		require.False(t, ok, path)
+	}
+}
+
+func Test_BlobCodecExpiry(t *testing.T) {
+	client := blobstore.NewTestClient()
+	now := time.Now()
+	values := PropagatedValues{TenantID: "short-lived", BlobNamePrefix: []string{t.Name()}}
+	options := BlobCodecOptions{
+		Retention: RetentionPolicy{Tenants: map[string]time.Duration{"short-lived": time.Hour}},
+	}
+
+	codec := NewBlobCodecWithOptions(client, values, options)
+	codec.now = func() time.Time { return now }
+
+	p, err := converter.GetDefaultDataConverter().ToPayload("really really really large giant payload")
+	require.NoError(t, err)
+	encoded, err := codec.Encode([]*commonpb.Payload{p})
+	require.NoError(t, err)
+	path := string(encoded[0].GetData())
+
+	expiresAt := now.Add(time.Hour).UTC().Format(time.RFC3339)
+	require.Equal(t, expiresAt, string(encoded[0].GetMetadata()[MetadataBlobExpiresAt]))
+	metadata, err := client.GetBlobMetadata(path)
+	require.NoError(t, err)
+	require.Equal(t, expiresAt, metadata[blobstore.MetadataExpiresAt])
+
+	_, err = codec.Decode(encoded)
+	require.NoError(t, err)
+
+	// after the expiry
+	codec.now = func() time.Time { return now.Add(2 * time.Hour) }
+	_, err = codec.Decode(encoded)
+	var expiredErr *ExpiredPayloadError
+	require.ErrorAs(t, err, &expiredErr)
+	require.Equal(t, path, expiredErr.Path)
+
+	codec.redactExpired = true
+	decoded, err := codec.Decode(encoded)
+	require.NoError(t, err)
+	var placeholder ExpiredPayload
+	require.NoError(t, converter.GetDefaultDataConverter().FromPayload(decoded[0], &placeholder))
+	require.Equal(t, "expired", placeholder.Redacted)
+	require.Equal(t, path, placeholder.Path)
+
+	deleted, err := blobstore.Sweep(client, DefaultBucket+"/short-lived/"+t.Name(), now.Add(2*time.Hour))
+	require.NoError(t, err)
+	require.Contains(t, deleted, path)
+	_, err = client.GetBlob(path)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
@@ -0,0 +1,74 @@
+package blobstore_data_converter
+
+import (
+	"failsafe"
+	"fmt"
+	"time"
+
+	commonpb "go.temporal.io/api/common/v1"
+)
+
+const (
+	// MetadataBlobExpiresAt is the claim-check metadata key holding the RFC 3339 time the blob will be purged at
+	MetadataBlobExpiresAt = "blobstore-expires-at"
+
+	// MetadataBlobRedacted marks the placeholder Decode returns for expired blobs, see BlobCodecOptions.RedactExpired
+	MetadataBlobRedacted = "blobstore-redacted"
+)
+
+// RetentionPolicy decides how long a tenant's blobs are kept, no matter what state the workflow is in.
+// A zero duration means the blobs never expire.
+type RetentionPolicy struct {
+	Default time.Duration
+	Tenants map[string]time.Duration
+}
+
+// TTL returns how long the tenant's blobs are kept
+func (p RetentionPolicy) TTL(tenant string) time.Duration {
+	if ttl, ok := p.Tenants[tenant]; ok {
+		return ttl
+	}
+	return p.Default
+}
+
+// ExpiredPayloadError is returned by BlobCodec.Decode when the blob has been, or is due to be, purged.
+// Use errors.As to tell it apart from a blob that's missing for other reasons.
+type ExpiredPayloadError struct {
+	Path      string
+	ExpiredAt time.Time
+}
+
+func (e *ExpiredPayloadError) Error() string {
+	return fmt.Sprintf("payload expired at %s: %s", e.ExpiredAt.Format(time.RFC3339), e.Path)
+}
+
+// ExpiredPayload is the json body of the placeholder returned instead of an ExpiredPayloadError
+type ExpiredPayload struct {
+	Redacted  string    `json:"redacted"`
+	Path      string    `json:"path"`
+	ExpiredAt time.Time `json:"expiredAt"`
+}
+
+// payload is the ExpiredPayload that RedactExpired returns in place of the blob
+func (e *ExpiredPayloadError) payload() (*commonpb.Payload, error) {
+	return failsafe.Placeholder(ExpiredPayload{
+		Redacted:  "expired",
+		Path:      e.Path,
+		ExpiredAt: e.ExpiredAt,
+	}, MetadataBlobRedacted, "expired")
+}
+
+// ClaimCheckExpiresAt reads the expiry stamped into the claim-check by Encode, ok is false when it never expires
+func ClaimCheckExpiresAt(p *commonpb.Payload) (time.Time, bool, error) {
+	v, ok := p.GetMetadata()[MetadataBlobExpiresAt]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+
+	expiresAt, err := time.Parse(time.RFC3339, string(v))
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid %s %q: %w", MetadataBlobExpiresAt, v, err)
+	}
+
+	return expiresAt, true, nil
+}
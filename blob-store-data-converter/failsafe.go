@@ -0,0 +1,67 @@
+package blobstore_data_converter
+
+import (
+	"errors"
+	"failsafe"
+	"io/fs"
+
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+)
+
+// MetadataBlobDecodeError marks the placeholder FailsafeCodec returns for a payload that failed to decode,
+// the value is the error class.
+const MetadataBlobDecodeError = "blobstore-decode-error"
+
+// Error classes of an UndecodablePayload
+const (
+	ErrorClassBlobNotFound = "blob-not-found"
+	ErrorClassCorruptBlob  = "corrupt-blob"
+	ErrorClassExpired      = "expired"
+	ErrorClassDecodeFailed = "decode-failed"
+)
+
+// UndecodablePayload is the json body of the placeholder FailsafeCodec returns for a payload that failed to decode
+type UndecodablePayload struct {
+	Undecodable string `json:"undecodable"`
+	Path        string `json:"path,omitempty"`
+	Error       string `json:"error"`
+}
+
+// FailsafeCodec decodes payloads one by one through a chain of codecs, swapping the ones that fail for an
+// UndecodablePayload placeholder. It's meant for the codec server, where one missing blob would otherwise fail
+// the whole request and hide the rest of the history.
+//
+// The codecs are in the order converter.NewPayloadCodecHTTPHandler takes them, Encode fails as a whole.
+type FailsafeCodec struct {
+	*failsafe.Codec
+}
+
+var _ = converter.PayloadCodec(&FailsafeCodec{}) // Ensure that FailsafeCodec implements converter.PayloadCodec
+
+// NewFailsafeCodec returns a FailsafeCodec for the chain of codecs
+func NewFailsafeCodec(codecs ...converter.PayloadCodec) *FailsafeCodec {
+	return &FailsafeCodec{failsafe.New(undecodablePayload, codecs...)}
+}
+
+// undecodablePayload classifies why the blob of p couldn't be decoded, a claim-check keeps its path
+func undecodablePayload(p *commonpb.Payload, err error) *commonpb.Payload {
+	class := ErrorClassDecodeFailed
+	var expiredErr *ExpiredPayloadError
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		class = ErrorClassBlobNotFound
+	case errors.Is(err, ErrCorruptBlob):
+		class = ErrorClassCorruptBlob
+	case errors.As(err, &expiredErr):
+		class = ErrorClassExpired
+	}
+
+	placeholder := UndecodablePayload{Undecodable: class, Error: err.Error()}
+	if string(p.GetMetadata()[converter.MetadataEncoding]) == MetadataEncodingBlobStorePlain {
+		placeholder.Path = string(p.GetData())
+	}
+	payload, _ := failsafe.Placeholder(placeholder, MetadataBlobDecodeError, class) // can't fail for strings
+
+	return payload
+}
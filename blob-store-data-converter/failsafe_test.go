@@ -0,0 +1,62 @@
+package blobstore_data_converter
+
+import (
+	"testing"
+
+	"blob-store-data-converter/blobstore"
+
+	"github.com/stretchr/testify/require"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+)
+
+func Test_FailsafeCodec(t *testing.T) {
+	client := blobstore.NewClientWithDir(t.TempDir())
+	codec := NewBlobCodec(client, PropagatedValues{BlobNamePrefix: []string{t.Name()}})
+
+	var plain []*commonpb.Payload
+	for _, v := range []string{"small", "really really really large giant payload", "missing blob of a really large payload", "corrupt blob of a really large payload"} {
+		p, err := converter.GetDefaultDataConverter().ToPayload(v)
+		require.NoError(t, err)
+		plain = append(plain, p)
+	}
+	encoded, err := codec.Encode(plain)
+	require.NoError(t, err)
+
+	missingPath := string(encoded[2].GetData())
+	encoded[2].Data = []byte(missingPath + "-gone")
+	require.NoError(t, client.SaveBlob(string(encoded[3].GetData()), []byte("not a payload")))
+
+	var failed int
+	failsafe := NewFailsafeCodec(codec)
+	failsafe.OnError = func(*commonpb.Payload, error) { failed++ }
+
+	// the plain codec fails the whole batch
+	_, err = codec.Decode(encoded)
+	require.Error(t, err)
+
+	decoded, err := failsafe.Decode(encoded)
+	require.NoError(t, err)
+	require.Len(t, decoded, 4)
+	require.Equal(t, 2, failed)
+
+	for i, want := range []string{"small", "really really really large giant payload"} {
+		var s string
+		require.NoError(t, converter.GetDefaultDataConverter().FromPayload(decoded[i], &s))
+		require.Equal(t, want, s)
+	}
+
+	for i, want := range []UndecodablePayload{
+		{Undecodable: ErrorClassBlobNotFound, Path: missingPath + "-gone"},
+		{Undecodable: ErrorClassCorruptBlob, Path: string(encoded[3].GetData())},
+	} {
+		p := decoded[i+2]
+		require.Equal(t, want.Undecodable, string(p.GetMetadata()[MetadataBlobDecodeError]))
+
+		var placeholder UndecodablePayload
+		require.NoError(t, converter.GetDefaultDataConverter().FromPayload(p, &placeholder))
+		require.Equal(t, want.Undecodable, placeholder.Undecodable)
+		require.Equal(t, want.Path, placeholder.Path)
+		require.NotEmpty(t, placeholder.Error)
+	}
+}
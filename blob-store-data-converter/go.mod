@@ -3,10 +3,12 @@ module blob-store-data-converter
 go 1.23.3
 
 require (
+	failsafe v0.0.0
 	github.com/google/uuid v1.6.0
 	github.com/stretchr/testify v1.10.0
 	go.temporal.io/api v1.42.0
 	go.temporal.io/sdk v1.30.0
+	tlsreload v0.0.0
 )
 
 require (
@@ -33,3 +35,7 @@ require (
 	google.golang.org/protobuf v1.34.2 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+replace failsafe => ../failsafe
+
+replace tlsreload => ../tlsreload
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"blob-store-data-converter/blobstore"
+	"flag"
+	"fmt"
+	"log"
+)
+
+var srcDir string
+var dstDir string
+var fromPrefix string
+var toPrefix string
+var journalPath string
+var redirectsPath string
+var mountsPath string
+
+func init() {
+	flag.StringVar(&srcDir, "src-dir", blobstore.DefaultDir, "Directory of the source blob store")
+	flag.StringVar(&dstDir, "dst-dir", "/tmp/temporal-sample/blob-store-data-converter/migrated-blobs", "Directory of the destination blob store")
+	flag.StringVar(&fromPrefix, "from", "blob://mybucket/", "Bucket or tenant prefix to migrate, e.g. blob://mybucket/tenant12/")
+	flag.StringVar(&toPrefix, "to", "", "Prefix to replace -from with in the destination, defaults to -from")
+	flag.StringVar(&journalPath, "journal", "migrate.journal", "Journal of migrated blobs, re-run with the same journal to resume")
+	flag.StringVar(&redirectsPath, "redirects", "redirects.json", "Redirect map to update for BlobCodec.Decode")
+	flag.StringVar(&mountsPath, "mounts", "mounts.json", "Mount table to update with the destination store")
+}
+
+// This copies every blob under a prefix from one blob store to another.
+// Once finished, the redirect map is updated so the old paths in the workflow histories still resolve,
+// and the mount table so the migrated prefix is served from the destination store. Pass both to the worker
+// and codec server.
+func main() {
+	flag.Parse()
+
+	opts := blobstore.MigrateOptions{
+		SourcePrefix:      fromPrefix,
+		DestinationPrefix: toPrefix,
+		JournalPath:       journalPath,
+		Logf:              log.Printf,
+	}
+
+	result, err := blobstore.Migrate(
+		blobstore.NewClientWithDir(srcDir),
+		blobstore.NewClientWithDir(dstDir),
+		opts,
+	)
+	if err != nil {
+		log.Fatalln("Migration failed, re-run to resume", err)
+	}
+	fmt.Printf("migrated %d blobs, %d already migrated\n", result.Copied, result.Skipped)
+
+	redirects, err := blobstore.LoadRedirects(redirectsPath)
+	if err != nil {
+		log.Fatalln("Unable to load redirects", err)
+	}
+	for from, to := range opts.Redirects() {
+		redirects[from] = to
+	}
+	if err := redirects.Save(redirectsPath); err != nil {
+		log.Fatalln("Unable to save redirects", err)
+	}
+	fmt.Println("updated redirects:", redirectsPath)
+
+	if dstDir == srcDir {
+		return
+	}
+	mounts, err := blobstore.LoadMounts(mountsPath)
+	if err != nil {
+		log.Fatalln("Unable to load mounts", err)
+	}
+	mounts[opts.DestinationPrefix] = dstDir
+	if err := mounts.Save(mountsPath); err != nil {
+		log.Fatalln("Unable to save mounts", err)
+	}
+	fmt.Println("updated mounts:", mountsPath)
+}
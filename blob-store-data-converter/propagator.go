@@ -3,6 +3,7 @@ package blobstore_data_converter
 import (
 	"context"
 	"fmt"
+	commonpb "go.temporal.io/api/common/v1"
 	"go.temporal.io/sdk/converter"
 	"go.temporal.io/sdk/workflow"
 )
@@ -111,3 +112,19 @@ func (s *propagator) ExtractToWorkflow(ctx workflow.Context, reader workflow.Hea
 
 	return ctx, nil
 }
+
+// ExtractPropagatedValues reads the PropagatedValues out of a header, e.g. from a workflow history event.
+// Like the propagator, a missing header results in UnknownTenant.
+func ExtractPropagatedValues(header *commonpb.Header) (PropagatedValues, error) {
+	value, ok := header.GetFields()[propagationKey]
+	if !ok {
+		return UnknownTenant(), nil
+	}
+
+	var data PropagatedValues
+	if err := converter.GetDefaultDataConverter().FromPayload(value, &data); err != nil {
+		return data, fmt.Errorf("failed to extract value from header: %w", err)
+	}
+
+	return data, nil
+}
@@ -4,6 +4,7 @@ import (
 	bsdc "blob-store-data-converter"
 	"blob-store-data-converter/blobstore"
 	"context"
+	"flag"
 	"go.temporal.io/api/enums/v1"
 	"go.temporal.io/sdk/workflow"
 	"log"
@@ -13,16 +14,24 @@ import (
 	"go.temporal.io/sdk/converter"
 )
 
+var retention time.Duration
+
+func init() {
+	flag.DurationVar(&retention, "retention", 0, "How long new blobs are kept before ./sweeper may delete them, 0 keeps them forever")
+}
+
 func main() {
+	flag.Parse()
 	ctx := context.Background()
 
 	bsClient := blobstore.NewClient()
 
 	// The client is a heavyweight object that should be created once per process.
 	c, err := client.Dial(client.Options{
-		DataConverter: bsdc.NewDataConverter(
+		DataConverter: bsdc.NewDataConverterWithOptions(
 			converter.GetDefaultDataConverter(),
 			bsClient,
+			bsdc.BlobCodecOptions{Retention: bsdc.RetentionPolicy{Default: retention}},
 		),
 		// Use a ContextPropagator so that the KeyID value set in the workflow context is
 		// also available in the context for activities.
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"blob-store-data-converter/blobstore"
+	"flag"
+	"log"
+	"time"
+
+	"go.temporal.io/sdk/worker"
+)
+
+var dir string
+var mountsPath string
+var prefix string
+var interval time.Duration
+
+func init() {
+	flag.StringVar(&dir, "dir", blobstore.DefaultDir, "Directory of the blob store")
+	flag.StringVar(&mountsPath, "mounts", "", "Mount table of blob prefixes migrated into other directories, see ./migrate")
+	flag.StringVar(&prefix, "prefix", "blob://mybucket/", "Bucket or tenant prefix to sweep")
+	flag.DurationVar(&interval, "interval", 0, "How often to sweep, 0 sweeps once and exits")
+}
+
+// This deletes every blob past the expiry stamped by BlobCodecOptions.Retention,
+// no matter what state the workflow that created it is in.
+func main() {
+	flag.Parse()
+
+	var mounts blobstore.Mounts
+	if mountsPath != "" {
+		var err error
+		mounts, err = blobstore.LoadMounts(mountsPath)
+		if err != nil {
+			log.Fatalln("Unable to load mounts", err)
+		}
+	}
+	store := mounts.Store(blobstore.NewClientWithDir(dir))
+	sweep := func() {
+		deleted, err := blobstore.Sweep(store, prefix, time.Now())
+		if err != nil {
+			log.Printf("Sweep incomplete, deleted %d expired blobs, failed to sweep:\n%v\n", len(deleted), err)
+			return
+		}
+		log.Printf("deleted %d expired blobs\n", len(deleted))
+	}
+
+	sweep()
+	if interval == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	interruptCh := worker.InterruptCh()
+	for {
+		select {
+		case <-ticker.C:
+			sweep()
+		case <-interruptCh:
+			return
+		}
+	}
+}
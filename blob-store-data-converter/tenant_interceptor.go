@@ -0,0 +1,134 @@
+package blobstore_data_converter
+
+import (
+	"context"
+	"strings"
+
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/log"
+	"go.temporal.io/sdk/workflow"
+)
+
+const (
+	// LogKeyTenantID and LogKeyBlobNamePrefix are the keys added to workflow and activity loggers
+	LogKeyTenantID       = "TenantID"
+	LogKeyBlobNamePrefix = "BlobNamePrefix"
+
+	// MetricTagTenantID is the tag added to workflow and activity metrics handlers.
+	// The BlobNamePrefix contains the workflow ID, it's only logged to keep the metric cardinality bounded.
+	MetricTagTenantID = "tenant_id"
+)
+
+// TenantInterceptor tags the workflow logger and activity logger with the PropagatedValues found in the
+// context, and the MetricsHandler with the tenant. This way every log line and metric is attributable
+// to a tenant without any changes to the workflow or activity code.
+//
+// It relies on NewContextPropagator to have already placed PropagatedValues on the context.
+type TenantInterceptor struct {
+	interceptor.WorkerInterceptorBase
+}
+
+var _ interceptor.WorkerInterceptor = (*TenantInterceptor)(nil) // ensure interface is implemented
+
+// NewTenantInterceptor returns a worker interceptor that tags loggers and metrics with the propagated tenant
+func NewTenantInterceptor() *TenantInterceptor {
+	return &TenantInterceptor{}
+}
+
+// InterceptWorkflow
+//
+//	Temporal Server > * > 1st line of the Workflow
+func (ti *TenantInterceptor) InterceptWorkflow(ctx workflow.Context, next interceptor.WorkflowInboundInterceptor) interceptor.WorkflowInboundInterceptor {
+	return &tenantWfInbound{
+		WorkflowInboundInterceptorBase: interceptor.WorkflowInboundInterceptorBase{Next: next},
+	}
+}
+
+// InterceptActivity
+//
+//	Temporal Server > * > 1st line of the Activity
+func (ti *TenantInterceptor) InterceptActivity(ctx context.Context, next interceptor.ActivityInboundInterceptor) interceptor.ActivityInboundInterceptor {
+	return &tenantActInbound{
+		ActivityInboundInterceptorBase: interceptor.ActivityInboundInterceptorBase{Next: next},
+	}
+}
+
+type tenantWfInbound struct {
+	interceptor.WorkflowInboundInterceptorBase
+}
+
+func (i *tenantWfInbound) Init(outbound interceptor.WorkflowOutboundInterceptor) error {
+	return i.Next.Init(&tenantWfOutbound{
+		WorkflowOutboundInterceptorBase: interceptor.WorkflowOutboundInterceptorBase{Next: outbound},
+	})
+}
+
+type tenantWfOutbound struct {
+	interceptor.WorkflowOutboundInterceptorBase
+}
+
+// GetLogger is looked up on every call, the workflow may have updated the PropagatedValues since it started
+func (o *tenantWfOutbound) GetLogger(ctx workflow.Context) log.Logger {
+	logger := o.Next.GetLogger(ctx)
+	if vals, ok := ctx.Value(PropagatedValuesKey).(PropagatedValues); ok {
+		return log.With(logger, vals.logKeyvals()...)
+	}
+	return logger
+}
+
+func (o *tenantWfOutbound) GetMetricsHandler(ctx workflow.Context) client.MetricsHandler {
+	handler := o.Next.GetMetricsHandler(ctx)
+	if vals, ok := ctx.Value(PropagatedValuesKey).(PropagatedValues); ok {
+		return handler.WithTags(vals.metricTags())
+	}
+	return handler
+}
+
+type tenantActInbound struct {
+	interceptor.ActivityInboundInterceptorBase
+}
+
+func (i *tenantActInbound) Init(outbound interceptor.ActivityOutboundInterceptor) error {
+	return i.Next.Init(&tenantActOutbound{
+		ActivityOutboundInterceptorBase: interceptor.ActivityOutboundInterceptorBase{Next: outbound},
+	})
+}
+
+type tenantActOutbound struct {
+	interceptor.ActivityOutboundInterceptorBase
+}
+
+func (o *tenantActOutbound) GetLogger(ctx context.Context) log.Logger {
+	logger := o.Next.GetLogger(ctx)
+	if vals, ok := ctx.Value(PropagatedValuesKey).(PropagatedValues); ok {
+		return log.With(logger, vals.logKeyvals()...)
+	}
+	return logger
+}
+
+func (o *tenantActOutbound) GetMetricsHandler(ctx context.Context) client.MetricsHandler {
+	handler := o.Next.GetMetricsHandler(ctx)
+	if vals, ok := ctx.Value(PropagatedValuesKey).(PropagatedValues); ok {
+		return handler.WithTags(vals.metricTags())
+	}
+	return handler
+}
+
+// blobNamePrefix matches how BlobCodec joins the prefix into the object name
+func (v PropagatedValues) blobNamePrefix() string {
+	return strings.Join(v.BlobNamePrefix, "_")
+}
+
+func (v PropagatedValues) logKeyvals() []interface{} {
+	return []interface{}{
+		LogKeyTenantID, v.TenantID,
+		LogKeyBlobNamePrefix, v.blobNamePrefix(),
+	}
+}
+
+func (v PropagatedValues) metricTags() map[string]string {
+	return map[string]string{
+		MetricTagTenantID: v.TenantID,
+	}
+}
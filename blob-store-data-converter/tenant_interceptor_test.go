@@ -0,0 +1,181 @@
+package blobstore_data_converter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/activity"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
+)
+
+// recordingLogger keeps every log line so we can assert on the keyvals
+type recordingLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *recordingLogger) record(msg string, keyvals ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, fmt.Sprint(msg, keyvals))
+}
+
+func (l *recordingLogger) Debug(msg string, keyvals ...interface{}) { l.record(msg, keyvals...) }
+func (l *recordingLogger) Info(msg string, keyvals ...interface{})  { l.record(msg, keyvals...) }
+func (l *recordingLogger) Warn(msg string, keyvals ...interface{})  { l.record(msg, keyvals...) }
+func (l *recordingLogger) Error(msg string, keyvals ...interface{}) { l.record(msg, keyvals...) }
+
+func (l *recordingLogger) find(msg string) string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, line := range l.lines {
+		if len(line) >= len(msg) && line[:len(msg)] == msg {
+			return line
+		}
+	}
+	return ""
+}
+
+func Test_TenantInterceptor(t *testing.T) {
+	logger := &recordingLogger{}
+	testSuite := &testsuite.WorkflowTestSuite{}
+	testSuite.SetLogger(logger)
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	env.SetWorkerOptions(worker.Options{
+		Interceptors: []interceptor.WorkerInterceptor{NewTenantInterceptor()},
+	})
+	env.SetContextPropagators([]workflow.ContextPropagator{NewContextPropagator()})
+	p, err := converter.GetDefaultDataConverter().ToPayload(PropagatedValues{
+		TenantID:       "test-tenant",
+		BlobNamePrefix: []string{t.Name()},
+	})
+	require.NoError(t, err)
+	env.SetHeader(&commonpb.Header{
+		Fields: map[string]*commonpb.Payload{
+			propagationKey: p,
+		},
+	})
+	env.RegisterActivity(Activity)
+
+	env.ExecuteWorkflow(Workflow, "Temporal")
+	require.True(t, env.IsWorkflowCompleted())
+	require.NoError(t, env.GetWorkflowError())
+
+	wfLine := logger.find("workflow started")
+	require.Contains(t, wfLine, LogKeyTenantID+" test-tenant")
+	require.Contains(t, wfLine, LogKeyBlobNamePrefix+" "+t.Name())
+
+	// the workflow updates the prefix before scheduling the activity
+	actLine := logger.find("Activity")
+	require.Contains(t, actLine, LogKeyTenantID+" test-tenant")
+	require.Contains(t, actLine, LogKeyBlobNamePrefix+" Workflow_default-test-workflow-id")
+}
+
+// recordingMetricsHandler keeps the tags of every counter increment
+type recordingMetricsHandler struct {
+	client.MetricsHandler
+	tags map[string]string
+	incs *[]map[string]string
+	mu   *sync.Mutex
+}
+
+func newRecordingMetricsHandler() *recordingMetricsHandler {
+	return &recordingMetricsHandler{
+		MetricsHandler: client.MetricsNopHandler,
+		tags:           map[string]string{},
+		incs:           &[]map[string]string{},
+		mu:             &sync.Mutex{},
+	}
+}
+
+func (h *recordingMetricsHandler) WithTags(tags map[string]string) client.MetricsHandler {
+	merged := map[string]string{}
+	for k, v := range h.tags {
+		merged[k] = v
+	}
+	for k, v := range tags {
+		merged[k] = v
+	}
+	return &recordingMetricsHandler{MetricsHandler: h.MetricsHandler, tags: merged, incs: h.incs, mu: h.mu}
+}
+
+func (h *recordingMetricsHandler) Counter(name string) client.MetricsCounter {
+	return recordingCounter{h: h, name: name}
+}
+
+// tagsOf returns the tags of the first increment of the counter
+func (h *recordingMetricsHandler) tagsOf(name string) map[string]string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, tags := range *h.incs {
+		if tags["name"] == name {
+			return tags
+		}
+	}
+	return nil
+}
+
+type recordingCounter struct {
+	h    *recordingMetricsHandler
+	name string
+}
+
+func (c recordingCounter) Inc(int64) {
+	c.h.mu.Lock()
+	defer c.h.mu.Unlock()
+	tags := map[string]string{"name": c.name}
+	for k, v := range c.h.tags {
+		tags[k] = v
+	}
+	*c.h.incs = append(*c.h.incs, tags)
+}
+
+func Test_TenantInterceptorMetricTags(t *testing.T) {
+	metrics := newRecordingMetricsHandler()
+	testSuite := &testsuite.WorkflowTestSuite{}
+	testSuite.SetMetricsHandler(metrics)
+	env := testSuite.NewTestWorkflowEnvironment()
+
+	env.SetWorkerOptions(worker.Options{
+		Interceptors: []interceptor.WorkerInterceptor{NewTenantInterceptor()},
+	})
+	env.SetContextPropagators([]workflow.ContextPropagator{NewContextPropagator()})
+	p, err := converter.GetDefaultDataConverter().ToPayload(PropagatedValues{
+		TenantID:       "test-tenant",
+		BlobNamePrefix: []string{"Workflow", "some-workflow-id"},
+	})
+	require.NoError(t, err)
+	env.SetHeader(&commonpb.Header{Fields: map[string]*commonpb.Payload{propagationKey: p}})
+
+	countActivity := func(ctx context.Context) error {
+		activity.GetMetricsHandler(ctx).Counter("activity_counter").Inc(1)
+		return nil
+	}
+	env.RegisterActivityWithOptions(countActivity, activity.RegisterOptions{Name: "count"})
+	env.ExecuteWorkflow(func(ctx workflow.Context) error {
+		workflow.GetMetricsHandler(ctx).Counter("workflow_counter").Inc(1)
+		ctx = workflow.WithActivityOptions(ctx, workflow.ActivityOptions{StartToCloseTimeout: time.Minute})
+		return workflow.ExecuteActivity(ctx, "count").Get(ctx, nil)
+	})
+	require.NoError(t, env.GetWorkflowError())
+
+	for _, name := range []string{"workflow_counter", "activity_counter"} {
+		tags := metrics.tagsOf(name)
+		require.NotNil(t, tags, name)
+		require.Equal(t, "test-tenant", tags[MetricTagTenantID], name)
+		for k, v := range tags {
+			require.NotContains(t, v, "some-workflow-id", "tag %s of %s", k, name)
+		}
+	}
+}
@@ -3,22 +3,59 @@ package main
 import (
 	bsdc "blob-store-data-converter"
 	"blob-store-data-converter/blobstore"
+	"flag"
 	"go.temporal.io/sdk/client"
 	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/interceptor"
 	"go.temporal.io/sdk/worker"
 	"go.temporal.io/sdk/workflow"
 	"log"
+	"time"
 )
 
+var dir string
+var redirectsPath string
+var mountsPath string
+var retention time.Duration
+
+func init() {
+	flag.StringVar(&dir, "dir", blobstore.DefaultDir, "Directory of the blob store")
+	flag.StringVar(&redirectsPath, "redirects", "", "Redirect map for blobs that have been migrated, see ./migrate")
+	flag.StringVar(&mountsPath, "mounts", "", "Mount table of blob prefixes migrated into other directories, see ./migrate")
+	flag.DurationVar(&retention, "retention", 0, "How long new blobs are kept before ./sweeper may delete them, 0 keeps them forever")
+}
+
 func main() {
-	bsClient := blobstore.NewClient()
+	flag.Parse()
+
+	var redirects blobstore.Redirects
+	var mounts blobstore.Mounts
+	if redirectsPath != "" {
+		var err error
+		redirects, err = blobstore.LoadRedirects(redirectsPath)
+		if err != nil {
+			log.Fatalln("Unable to load redirects", err)
+		}
+	}
+	if mountsPath != "" {
+		var err error
+		mounts, err = blobstore.LoadMounts(mountsPath)
+		if err != nil {
+			log.Fatalln("Unable to load mounts", err)
+		}
+	}
+	bsClient := mounts.Store(blobstore.NewClientWithDir(dir))
 
 	// The client and worker are heavyweight objects that should be created once per process.
 	c, err := client.Dial(client.Options{
 		// Calls to the blob store will probably be a network call with inherent latency, this may trigger deadlock detection
-		DataConverter: workflow.DataConverterWithoutDeadlockDetection(bsdc.NewDataConverter(
+		DataConverter: workflow.DataConverterWithoutDeadlockDetection(bsdc.NewDataConverterWithOptions(
 			converter.GetDefaultDataConverter(),
 			bsClient,
+			bsdc.BlobCodecOptions{
+				Redirects: redirects,
+				Retention: bsdc.RetentionPolicy{Default: retention},
+			},
 		)),
 
 		// Use a ContextPropagator so that the KeyID value set in the workflow context is
@@ -32,7 +69,12 @@ func main() {
 	}
 	defer c.Close()
 
-	w := worker.New(c, "blobstore_codec", worker.Options{})
+	w := worker.New(c, "blobstore_codec", worker.Options{
+		// Tags every workflow/activity log line and metric with the propagated tenant
+		Interceptors: []interceptor.WorkerInterceptor{
+			bsdc.NewTenantInterceptor(),
+		},
+	})
 
 	w.RegisterWorkflow(bsdc.Workflow)
 	w.RegisterActivity(bsdc.Activity)
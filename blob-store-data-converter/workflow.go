@@ -30,7 +30,7 @@ func Workflow(ctx workflow.Context, name string) (string, error) {
 
 	fmt.Printf("workflow injected from starter ctx value: %+v\n", ctxVal)
 	wfInfo := workflow.GetInfo(ctx)
-	ctxVal.BlobNamePrefix = []string{wfInfo.WorkflowType.Name, wfInfo.WorkflowExecution.ID}
+	ctxVal.BlobNamePrefix = ExecutionBlobNamePrefix(wfInfo.WorkflowType.Name, wfInfo.WorkflowExecution.ID)
 	ctx = workflow.WithValue(ctx, PropagatedValuesKey, ctxVal)
 	fmt.Printf("workflow updated in workflow ctx value: %+v\n", ctxVal)
 
@@ -51,6 +51,11 @@ func Workflow(ctx workflow.Context, name string) (string, error) {
 	return result, nil
 }
 
+// ExecutionBlobNamePrefix is the BlobNamePrefix used for payloads offloaded by a workflow execution
+func ExecutionBlobNamePrefix(workflowType, workflowID string) []string {
+	return []string{workflowType, workflowID}
+}
+
 func Activity(ctx context.Context, info map[string]string) (string, error) {
 	logger := activity.GetLogger(ctx)
 	logger.Info("Activity", "info", info)
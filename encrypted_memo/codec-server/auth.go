@@ -0,0 +1,350 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/time/rate"
+)
+
+// namespaceHeader is set by the Temporal UI and CLI to the namespace of the payloads
+const namespaceHeader = "X-Namespace"
+
+// namespaceKey is the context key of the namespace the caller's claims authorize the request for
+type namespaceKey struct{}
+
+// namespaceFromContext returns the namespace authWrap authorized the request for
+func namespaceFromContext(ctx context.Context) (string, bool) {
+	namespace, ok := ctx.Value(namespaceKey{}).(string)
+	return namespace, ok
+}
+
+// jwk is a public key of a JWKS file, RSA and EC P-256/P-384/P-521 keys are supported
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// loadJWKS reads the public keys of a JWKS file, keyed by their kid
+func loadJWKS(path string) (map[string]interface{}, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read jwks: %w", err)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(b, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse jwks %s: %w", path, err)
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, k := range set.Keys {
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("invalid jwks key %q: %w", k.Kid, err)
+		}
+		keys[k.Kid] = key
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no keys in jwks %s", path)
+	}
+
+	return keys, nil
+}
+
+func (k jwk) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("point is not on the curve")
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+// codecClaims are the JWT claims of a caller.
+// Permissions use the Temporal claim mapper format, "<namespace>:<role>", e.g. "default:read".
+type codecClaims struct {
+	jwt.RegisteredClaims
+	Permissions []string `json:"permissions"`
+}
+
+// namespaces are the namespaces the claims grant any role on
+func (c *codecClaims) namespaces() []string {
+	var namespaces []string
+	for _, p := range c.Permissions {
+		ns, _, ok := strings.Cut(p, ":")
+		if ok && !slices.Contains(namespaces, ns) {
+			namespaces = append(namespaces, ns)
+		}
+	}
+
+	return namespaces
+}
+
+// authenticator validates bearer JWTs against the keys of a JWKS file
+type authenticator struct {
+	keys   map[string]interface{}
+	parser *jwt.Parser
+}
+
+func newAuthenticator(jwksPath, issuer, audience string) (*authenticator, error) {
+	keys, err := loadJWKS(jwksPath)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}),
+		jwt.WithExpirationRequired(),
+	}
+	if issuer != "" {
+		opts = append(opts, jwt.WithIssuer(issuer))
+	}
+	if audience != "" {
+		opts = append(opts, jwt.WithAudience(audience))
+	}
+
+	return &authenticator{keys: keys, parser: jwt.NewParser(opts...)}, nil
+}
+
+// authenticate returns the claims of the request's bearer token
+func (a *authenticator) authenticate(r *http.Request) (*codecClaims, error) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return nil, errors.New("missing bearer token")
+	}
+
+	claims := &codecClaims{}
+	_, err := a.parser.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		if key, ok := a.keys[kid]; ok {
+			return key, nil
+		}
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	})
+	if err != nil {
+		return nil, err
+	}
+	// callers are rate limited by subject, tokens without one would all share a limit
+	if claims.Subject == "" {
+		return nil, errors.New("missing sub claim")
+	}
+
+	return claims, nil
+}
+
+// rateLimiter limits the requests of each caller.
+// Limiters idle for longer than it takes to refill their burst are evicted, a new one allows the same.
+type rateLimiter struct {
+	limit rate.Limit
+	burst int
+	idle  time.Duration
+	now   func() time.Time // overridden by tests
+
+	mu        sync.Mutex
+	limiters  map[string]*callerLimiter
+	lastEvict time.Time
+}
+
+// callerLimiter is the limiter of a caller and when it was last used
+type callerLimiter struct {
+	*rate.Limiter
+	lastSeen time.Time
+}
+
+func newRateLimiter(limit rate.Limit, burst int) *rateLimiter {
+	idle := time.Minute
+	if refill := time.Duration(float64(burst) / float64(limit) * float64(time.Second)); limit > 0 && refill > idle {
+		idle = refill
+	}
+
+	return &rateLimiter{limit: limit, burst: burst, idle: idle, now: time.Now, limiters: map[string]*callerLimiter{}}
+}
+
+func (l *rateLimiter) allow(caller string) bool {
+	now := l.now()
+
+	l.mu.Lock()
+	if now.Sub(l.lastEvict) > l.idle {
+		for c, limiter := range l.limiters {
+			if now.Sub(limiter.lastSeen) > l.idle {
+				delete(l.limiters, c)
+			}
+		}
+		l.lastEvict = now
+	}
+	limiter, ok := l.limiters[caller]
+	if !ok {
+		limiter = &callerLimiter{Limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.limiters[caller] = limiter
+	}
+	limiter.lastSeen = now
+	l.mu.Unlock()
+
+	return limiter.AllowN(now, 1)
+}
+
+// size is the number of callers with a limiter
+func (l *rateLimiter) size() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.limiters)
+}
+
+// authWrap rate limits the remote IP, authenticates the caller, rate limits it and authorizes the namespace.
+// The remote IP is limited before authenticating, so invalid tokens can't be used to keep the server busy.
+// The namespace is the one the claims grant, X-Namespace only picks one when they grant several.
+// It's passed on in the request context, see namespaceFromContext.
+// Without an authenticator only the remote IP is rate limited and no namespace is authorized.
+func authWrap(next http.Handler, auth *authenticator, limiter *rateLimiter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		caller, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			caller = r.RemoteAddr
+		}
+		namespace := r.Header.Get(namespaceHeader)
+
+		reject := func(status int, reason string) {
+			log.Printf("rejected %s %s from %s for namespace %q: %s", r.Method, r.URL.Path, caller, namespace, reason)
+			if status == http.StatusUnauthorized {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+			}
+			http.Error(w, http.StatusText(status), status)
+		}
+
+		if limiter != nil && !limiter.allow("ip:"+caller) {
+			reject(http.StatusTooManyRequests, "rate limited")
+			return
+		}
+
+		var claims *codecClaims
+		if auth != nil {
+			claims, err = auth.authenticate(r)
+			if err != nil {
+				reject(http.StatusUnauthorized, err.Error())
+				return
+			}
+			caller = claims.Subject
+
+			if limiter != nil && !limiter.allow("sub:"+caller) {
+				reject(http.StatusTooManyRequests, "rate limited")
+				return
+			}
+		}
+
+		if claims != nil {
+			granted := claims.namespaces()
+			switch {
+			case namespace == "" && len(granted) == 1:
+				namespace = granted[0]
+			case namespace == "" && len(granted) > 1:
+				reject(http.StatusBadRequest, "missing "+namespaceHeader+" header")
+				return
+			case !slices.Contains(granted, namespace):
+				reject(http.StatusForbidden, "no permission for namespace")
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), namespaceKey{}, namespace))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// corsWrap only allows the allowedOrigins, "*" allows any origin.
+// Only origins listed explicitly may send credentials, any other origin allowed by "*" gets a literal "*".
+func corsWrap(next http.Handler, allowedOrigins []string) http.Handler {
+	allowed := make(map[string]bool, len(allowedOrigins))
+	for _, o := range allowedOrigins {
+		allowed[strings.TrimSuffix(o, "/")] = true
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		w.Header().Add("Vary", "Origin")
+
+		// requests without an Origin, like the CLI's, aren't cross-origin
+		if origin != "" {
+			if !allowed["*"] && !allowed[origin] {
+				log.Printf("rejected %s %s from origin %q: origin not allowed", r.Method, r.URL.Path, origin)
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+
+			if allowed[origin] {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			} else {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			}
+			w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Namespace")
+			w.Header().Set("Access-Control-Max-Age", "600")
+		}
+
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
+)
+
+func writeJWKS(t *testing.T, rsaKey *rsa.PublicKey, ecKey *ecdsa.PublicKey) string {
+	t.Helper()
+
+	b64 := base64.RawURLEncoding.EncodeToString
+	set := map[string][]jwk{"keys": {
+		{Kty: "RSA", Kid: "rsa-1", N: b64(rsaKey.N.Bytes()), E: b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+		{Kty: "EC", Kid: "ec-1", Crv: "P-256", X: b64(ecKey.X.Bytes()), Y: b64(ecKey.Y.Bytes())},
+	}}
+	b, err := json.Marshal(set)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, b, 0o600))
+	return path
+}
+
+func signToken(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims codecClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	s, err := token.SignedString(key)
+	require.NoError(t, err)
+	return s
+}
+
+func Test_AuthWrap(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	auth, err := newAuthenticator(writeJWKS(t, &rsaKey.PublicKey, &ecKey.PublicKey), "https://issuer", "codec")
+	require.NoError(t, err)
+
+	handler := corsWrap(authWrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		namespace, _ := namespaceFromContext(r.Context())
+		_, _ = w.Write([]byte(namespace))
+	}), auth, newRateLimiter(rate.Limit(1), 2)), []string{"http://localhost:8233"})
+
+	claims := func(subject string, permissions ...string) codecClaims {
+		return codecClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   subject,
+				Issuer:    "https://issuer",
+				Audience:  jwt.ClaimStrings{"codec"},
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			},
+			Permissions: permissions,
+		}
+	}
+	expired := claims("expired", "default:read")
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	wrongIssuer := claims("wrong-issuer", "default:read")
+	wrongIssuer.Issuer = "https://other"
+
+	doFrom := func(remoteAddr, method, token, namespace, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/decode", nil)
+		req.RemoteAddr = remoteAddr
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		if namespace != "" {
+			req.Header.Set(namespaceHeader, namespace)
+		}
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	// each request comes from another IP, so only the limits of the callers apply
+	requests := 0
+	do := func(method, token, namespace, origin string) *httptest.ResponseRecorder {
+		requests++
+		return doFrom(fmt.Sprintf("192.0.2.%d:1234", requests), method, token, namespace, origin)
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		ns     string
+		origin string
+		status int
+	}{
+		{"no token", "", "default", "", http.StatusUnauthorized},
+		{"rsa", signToken(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, claims("rsa", "default:read")), "default", "", http.StatusOK},
+		{"ec", signToken(t, jwt.SigningMethodES256, "ec-1", ecKey, claims("ec", "default:admin")), "default", "", http.StatusOK},
+		{"other namespace", signToken(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, claims("other-ns", "other:read")), "default", "", http.StatusForbidden},
+		{"no namespace", signToken(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, claims("no-ns", "default:read")), "", "", http.StatusOK},
+		{"no namespace of several", signToken(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, claims("several", "default:read", "other:read")), "", "", http.StatusBadRequest},
+		{"no subject", signToken(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, claims("", "default:read")), "default", "", http.StatusUnauthorized},
+		{"expired", signToken(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, expired), "default", "", http.StatusUnauthorized},
+		{"wrong issuer", signToken(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, wrongIssuer), "default", "", http.StatusUnauthorized},
+		{"unknown signer", signToken(t, jwt.SigningMethodRS256, "rsa-1", otherKey, claims("forged", "default:read")), "default", "", http.StatusUnauthorized},
+		{"unknown kid", signToken(t, jwt.SigningMethodRS256, "rsa-2", rsaKey, claims("kid", "default:read")), "default", "", http.StatusUnauthorized},
+		{"allowed origin", signToken(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, claims("ui", "default:read")), "default", "http://localhost:8233", http.StatusOK},
+		{"other origin", signToken(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, claims("evil", "default:read")), "default", "https://evil.example", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := do(http.MethodPost, tt.token, tt.ns, tt.origin)
+			require.Equal(t, tt.status, rec.Code)
+			if tt.status == http.StatusOK {
+				require.Equal(t, "default", rec.Body.String(), "the namespace is the one the claims grant")
+			}
+			if tt.status == http.StatusOK && tt.origin != "" {
+				require.Equal(t, tt.origin, rec.Header().Get("Access-Control-Allow-Origin"))
+			}
+		})
+	}
+
+	// preflight requests don't carry the token
+	rec := do(http.MethodOptions, "", "", "http://localhost:8233")
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	require.Equal(t, "http://localhost:8233", rec.Header().Get("Access-Control-Allow-Origin"))
+
+	// each caller has its own burst of 2
+	token := signToken(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, claims("busy", "default:read"))
+	require.Equal(t, http.StatusOK, do(http.MethodPost, token, "default", "").Code)
+	require.Equal(t, http.StatusOK, do(http.MethodPost, token, "default", "").Code)
+	require.Equal(t, http.StatusTooManyRequests, do(http.MethodPost, token, "default", "").Code)
+	require.Equal(t, http.StatusOK, do(http.MethodPost, signToken(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, claims("idle", "default:read")), "default", "").Code)
+
+	// each IP has its own burst of 2, limited before the token is checked
+	require.Equal(t, http.StatusUnauthorized, doFrom("198.51.100.1:1234", http.MethodPost, "invalid", "default", "").Code)
+	require.Equal(t, http.StatusUnauthorized, doFrom("198.51.100.1:1234", http.MethodPost, "invalid", "default", "").Code)
+	require.Equal(t, http.StatusTooManyRequests, doFrom("198.51.100.1:1234", http.MethodPost, "invalid", "default", "").Code)
+	require.Equal(t, http.StatusTooManyRequests, doFrom("198.51.100.1:1234", http.MethodPost, token, "default", "").Code)
+}
+
+func Test_CORSWrap(t *testing.T) {
+	handler := corsWrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}), []string{"*", "http://localhost:8233"})
+
+	do := func(origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodOptions, "/decode", nil)
+		req.Header.Set("Origin", origin)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// listed origins may send credentials
+	rec := do("http://localhost:8233")
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	require.Equal(t, "http://localhost:8233", rec.Header().Get("Access-Control-Allow-Origin"))
+	require.Equal(t, "true", rec.Header().Get("Access-Control-Allow-Credentials"))
+
+	// any other origin only gets "*", which browsers don't send credentials to
+	rec = do("https://other.example")
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	require.Equal(t, "*", rec.Header().Get("Access-Control-Allow-Origin"))
+	require.Empty(t, rec.Header().Get("Access-Control-Allow-Credentials"))
+}
+
+func Test_RateLimiterEvictsIdleCallers(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	limiter := newRateLimiter(rate.Limit(1), 2)
+	limiter.now = func() time.Time { return now }
+
+	require.True(t, limiter.allow("a"))
+	require.True(t, limiter.allow("a"))
+	require.False(t, limiter.allow("a"))
+	require.True(t, limiter.allow("b"))
+	require.Equal(t, 2, limiter.size())
+
+	// a caller idle long enough to refill its burst is dropped, a new limiter allows the same
+	now = now.Add(2 * time.Minute)
+	require.True(t, limiter.allow("c"))
+	require.Equal(t, 1, limiter.size())
+	require.True(t, limiter.allow("a"))
+	require.True(t, limiter.allow("a"))
+	require.False(t, limiter.allow("a"))
+}
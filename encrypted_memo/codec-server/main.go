@@ -2,61 +2,156 @@ package main
 
 import (
 	"encrypted_memo/codec"
+	"encrypted_memo/internal/keyflags"
 	"flag"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
+	"syscall"
+	"tlsreload"
 
+	commonpb "go.temporal.io/api/common/v1"
 	"go.temporal.io/sdk/converter"
+	"golang.org/x/time/rate"
 )
 
 var portFlag int
+var jwksFlag string
+var issuerFlag string
+var audienceFlag string
+var allowedOriginsFlag string
+var rateFlag float64
+var burstFlag int
+var tlsCertFlag string
+var tlsKeyFlag string
+var tlsClientCAFlag string
+var namespaceKeysFlag string
 
 func init() {
 	flag.IntVar(&portFlag, "port", 8081, "Port to listen on")
+	flag.StringVar(&jwksFlag, "jwks", "", "JWKS file to validate bearer JWTs with, without it requests are unauthenticated and only localhost is listened on")
+	flag.StringVar(&issuerFlag, "issuer", "", "Required JWT issuer")
+	flag.StringVar(&audienceFlag, "audience", "", "Required JWT audience")
+	flag.StringVar(&allowedOriginsFlag, "allowed-origins", "http://localhost:8233", "Comma separated origins allowed to call the codec server, * allows any")
+	flag.Float64Var(&rateFlag, "rate", 10, "Requests per second allowed per caller")
+	flag.IntVar(&burstFlag, "burst", 20, "Request burst allowed per caller")
+	flag.StringVar(&tlsCertFlag, "tls-cert", "", "Certificate file to serve HTTPS with, reloaded on SIGHUP")
+	flag.StringVar(&tlsKeyFlag, "tls-key", "", "Private key file of -tls-cert")
+	flag.StringVar(&tlsClientCAFlag, "tls-client-ca", "", "CA bundle to verify client certificates with, enables mTLS")
+	flag.StringVar(&namespaceKeysFlag, "namespace-keys", "", "JSON file mapping each namespace to the key IDs it may decode with, e.g. {\"default\": [\"key-1\"]}, requires -jwks")
+}
+
+// newCodecHandler decodes with the codecs of the worker's DataConverter, payloads are decoded one by one,
+// so one that fails doesn't hide the rest of the history
+func newCodecHandler(keyProvider codec.KeyProvider) http.Handler {
+	options := codec.DataConverterOptions{KeyProvider: keyProvider, Compress: true}
+	failsafe := codec.NewFailsafeCodec(append(
+		codec.NewPayloadCodecs(options),
+		// decodes last, after the whole payload is decrypted and decompressed
+		&codec.FieldCodec{Codec: codec.NewCodec(options)},
+	)...)
+	failsafe.OnError = func(p *commonpb.Payload, err error) {
+		log.Printf("Failed to decode payload with key %q: %v", p.GetMetadata()[codec.MetadataEncryptionKeyID], err)
+	}
+
+	return converter.NewPayloadCodecHTTPHandler(failsafe)
 }
 
 func main() {
 	flag.Parse()
 
-	handler := converter.NewPayloadCodecHTTPHandler(&codec.Codec{}, converter.NewZlibCodec(converter.ZlibCodecOptions{AlwaysEncode: true}))
+	keyProvider, err := keyflags.KeyProvider()
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	handler = corsWrap(handler)
+	// without -jwks the codec server only listens on localhost, anyone who can reach it can decode payloads
+	host := "127.0.0.1"
+	var auth *authenticator
+	if jwksFlag != "" {
+		auth, err = newAuthenticator(jwksFlag, issuerFlag, audienceFlag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		host = "0.0.0.0"
+	} else {
+		log.Println("Warning: no -jwks, only listening on localhost, anyone who can reach it can decode payloads")
+	}
+
+	// the namespace comes from the verified claims, so each namespace only decodes with its own keys
+	var handler http.Handler
+	if namespaceKeysFlag != "" {
+		if auth == nil {
+			log.Fatal("-namespace-keys requires -jwks, the namespace is taken from the verified claims")
+		}
+		namespaceKeys, err := loadNamespaceKeys(namespaceKeysFlag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		handlers := make(map[string]http.Handler, len(namespaceKeys))
+		for namespace, keyIDs := range namespaceKeys {
+			handlers[namespace] = newCodecHandler(codec.NewScopedKeyProvider(keyProvider, keyIDs))
+		}
+		handler = namespaceHandler(handlers)
+	} else {
+		log.Println("Warning: no -namespace-keys, callers of any namespace can decode payloads of every key")
+		handler = newCodecHandler(keyProvider)
+	}
+
+	handler = authWrap(handler, auth, newRateLimiter(rate.Limit(rateFlag), burstFlag))
+	handler = corsWrap(handler, strings.Split(allowedOriginsFlag, ","))
 
 	srv := &http.Server{
-		Addr:    "0.0.0.0:" + strconv.Itoa(portFlag),
+		Addr:    host + ":" + strconv.Itoa(portFlag),
 		Handler: handler,
 	}
 
+	var reloader *tlsreload.Reloader
+	if tlsCertFlag != "" || tlsKeyFlag != "" || tlsClientCAFlag != "" {
+		if tlsCertFlag == "" || tlsKeyFlag == "" {
+			log.Fatal("-tls-cert and -tls-key are both required to serve HTTPS")
+		}
+		reloader, err = tlsreload.New(tlsCertFlag, tlsKeyFlag, tlsClientCAFlag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		srv.TLSConfig = reloader.TLSConfig()
+	}
+
 	errCh := make(chan error, 1)
-	go func() { errCh <- srv.ListenAndServe() }()
+	go func() {
+		if reloader != nil {
+			errCh <- srv.ListenAndServeTLS("", "")
+		} else {
+			errCh <- srv.ListenAndServe()
+		}
+	}()
 
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, os.Interrupt)
-
-	select {
-	case <-sigCh:
-		_ = srv.Close()
-	case err := <-errCh:
-		log.Fatal(err)
+	// without TLS there's nothing to reload, SIGHUP keeps its default behavior
+	var hupCh chan os.Signal
+	if reloader != nil {
+		hupCh = make(chan os.Signal, 1)
+		signal.Notify(hupCh, syscall.SIGHUP)
 	}
-}
 
-func corsWrap(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Namespace")
-		w.Header().Set("Access-Control-Max-Age", "600")
-		w.Header().Set("Vary", "Origin")
-
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusNoContent)
+	for {
+		select {
+		case <-hupCh:
+			if err := reloader.Reload(); err != nil {
+				log.Println("Failed to reload TLS certificates, keeping the current ones:", err)
+			} else {
+				log.Println("Reloaded TLS certificates")
+			}
+		case <-sigCh:
+			_ = srv.Close()
 			return
+		case err := <-errCh:
+			log.Fatal(err)
 		}
-
-		next.ServeHTTP(w, r)
-	})
+	}
 }
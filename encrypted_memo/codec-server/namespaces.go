@@ -0,0 +1,42 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+)
+
+// loadNamespaceKeys reads the key IDs each namespace may decode with, e.g.
+//
+//	{"default": ["key-1", "key-2"], "tenant1": ["tenant1"]}
+func loadNamespaceKeys(file string) (map[string][]string, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read namespace keys: %w", err)
+	}
+
+	keys := map[string][]string{}
+	if err := json.Unmarshal(b, &keys); err != nil {
+		return nil, fmt.Errorf("failed to parse namespace keys %s: %w", file, err)
+	}
+
+	return keys, nil
+}
+
+// namespaceHandler routes each request to the handler of the namespace authWrap authorized it for,
+// requests of other namespaces or without an authorized one are rejected
+func namespaceHandler(handlers map[string]http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		namespace, _ := namespaceFromContext(r.Context())
+		handler, ok := handlers[namespace]
+		if !ok {
+			log.Printf("rejected %s %s from %s for namespace %q: no keys", r.Method, r.URL.Path, r.RemoteAddr, namespace)
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
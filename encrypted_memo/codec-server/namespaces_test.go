@@ -0,0 +1,77 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"encrypted_memo/codec"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+)
+
+func Test_NamespaceHandler(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	auth, err := newAuthenticator(writeJWKS(t, &rsaKey.PublicKey, &ecKey.PublicKey), "", "")
+	require.NoError(t, err)
+
+	handlers := namespaceHandler(map[string]http.Handler{
+		"tenant1": newCodecHandler(codec.NewScopedKeyProvider(nil, []string{"tenant1"})),
+		"tenant2": newCodecHandler(codec.NewScopedKeyProvider(nil, []string{"tenant2"})),
+	})
+	srv := httptest.NewServer(authWrap(handlers, auth, nil))
+	defer srv.Close()
+	// without authWrap no namespace is authorized, the header alone routes nowhere
+	unauthenticated := httptest.NewServer(handlers)
+	defer unauthenticated.Close()
+
+	token := signToken(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, codecClaims{
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "tenant1-user", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))},
+		Permissions:      []string{"tenant1:read"},
+	})
+
+	encode := func(keyID string) *commonpb.Payload {
+		p, err := codec.NewEncryptionDataConverter(converter.GetDefaultDataConverter(), codec.DataConverterOptions{KeyID: keyID}).ToPayload("secret")
+		require.NoError(t, err)
+		return p
+	}
+	decodeFrom := func(endpoint, namespace string, payloads ...*commonpb.Payload) ([]*commonpb.Payload, error) {
+		remote := converter.NewRemotePayloadCodec(converter.RemotePayloadCodecOptions{
+			Endpoint: endpoint,
+			ModifyRequest: func(r *http.Request) error {
+				r.Header.Set("Authorization", "Bearer "+token)
+				r.Header.Set(namespaceHeader, namespace)
+				return nil
+			},
+		})
+		return remote.Decode(payloads)
+	}
+	decode := func(namespace string, payloads ...*commonpb.Payload) ([]*commonpb.Payload, error) {
+		return decodeFrom(srv.URL, namespace, payloads...)
+	}
+
+	decoded, err := decode("tenant1", encode("tenant1"), encode("tenant2"))
+	require.NoError(t, err)
+	var result string
+	require.NoError(t, converter.GetDefaultDataConverter().FromPayload(decoded[0], &result))
+	require.Equal(t, "secret", result)
+	require.NotEmpty(t, decoded[1].GetMetadata()[codec.MetadataEncryptionDecodeError], "the key of another namespace isn't used")
+
+	// the claims only grant tenant1, the header can't pick tenant2
+	_, err = decode("tenant2", encode("tenant2"))
+	require.ErrorContains(t, err, http.StatusText(http.StatusForbidden))
+
+	_, err = decodeFrom(unauthenticated.URL, "tenant1", encode("tenant1"))
+	require.ErrorContains(t, err, http.StatusText(http.StatusForbidden))
+}
@@ -0,0 +1,88 @@
+package codec
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"sync"
+)
+
+// aeadCache caches AEAD instances per cipher and key ID, so the AES key schedule isn't recomputed for each payload.
+// The zero value is ready to use, and a nil *aeadCache doesn't cache.
+//
+// Keys are still fetched from the KeyProvider for every batch and an entry is only reused for the same key,
+// so a rotated or shredded key takes effect right away. forget drops the entries of a shredded key, and get
+// doesn't cache it again when it raced with the shredding.
+type aeadCache struct {
+	mu       sync.RWMutex
+	entries  map[aeadCacheKey]aeadCacheEntry
+	shredded map[string]bool
+}
+
+type aeadCacheKey struct {
+	cipher Cipher
+	keyID  string
+}
+
+type aeadCacheEntry struct {
+	key  []byte
+	aead cipher.AEAD
+}
+
+// aeadCacher is implemented by the KeyProviders that hold the AEAD cache of their keys, their ShredKey clears it
+type aeadCacher interface {
+	aeadCache() *aeadCache
+}
+
+// get returns the cached AEAD of the key, or creates it
+func (c *aeadCache) get(ci Cipher, keyID string, key []byte) (cipher.AEAD, error) {
+	if c == nil {
+		return newAEAD(ci, key)
+	}
+	cacheKey := aeadCacheKey{cipher: ci, keyID: keyID}
+
+	c.mu.RLock()
+	entry, ok := c.entries[cacheKey]
+	c.mu.RUnlock()
+	if ok && subtle.ConstantTimeCompare(entry.key, key) == 1 {
+		return entry.aead, nil
+	}
+
+	aead, err := newAEAD(ci, key)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// the key was fetched before it was shredded, it's used for this batch but not kept
+	if c.shredded[keyID] {
+		return aead, nil
+	}
+	if c.entries == nil {
+		c.entries = map[aeadCacheKey]aeadCacheEntry{}
+	}
+	c.entries[cacheKey] = aeadCacheEntry{key: append([]byte(nil), key...), aead: aead}
+
+	return aead, nil
+}
+
+// forget drops the AEADs of a shredded key, it's never cached again
+func (c *aeadCache) forget(keyID string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.shredded == nil {
+		c.shredded = map[string]bool{}
+	}
+	c.shredded[keyID] = true
+	for cacheKey, entry := range c.entries {
+		if cacheKey.keyID == keyID {
+			zero(entry.key)
+			delete(c.entries, cacheKey)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package codec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/converter"
+)
+
+func Test_AEADCache(t *testing.T) {
+	cache := &aeadCache{}
+
+	a, err := cache.get(CipherAES256GCM, "key-1", testKey1)
+	require.NoError(t, err)
+	b, err := cache.get(CipherAES256GCM, "key-1", append([]byte(nil), testKey1...))
+	require.NoError(t, err)
+	require.Same(t, a, b, "the AEAD is reused for the same key")
+
+	chacha, err := cache.get(CipherChaCha20Poly1305, "key-1", testKey1)
+	require.NoError(t, err)
+	require.NotSame(t, a, chacha, "each cipher has its own AEAD")
+
+	// a key ID that now resolves to another key, e.g. a different KeyProvider, gets a new AEAD
+	replaced, err := cache.get(CipherAES256GCM, "key-1", testKey2)
+	require.NoError(t, err)
+	require.NotSame(t, a, replaced)
+	sealed, err := seal(replaced, []byte("secret"), nil)
+	require.NoError(t, err)
+	_, err = open(a, sealed, nil)
+	require.ErrorIs(t, err, ErrDecryptionFailed)
+
+	cache.forget("key-1")
+	require.Empty(t, cache.entries)
+
+	// a key fetched before it was shredded still decrypts its batch, but isn't cached again
+	_, err = cache.get(CipherAES256GCM, "key-1", testKey1)
+	require.NoError(t, err)
+	require.Empty(t, cache.entries)
+
+	var none *aeadCache
+	_, err = none.get(CipherAES256GCM, "key-1", testKey1)
+	require.NoError(t, err)
+}
+
+func Test_AEADCacheShredKey(t *testing.T) {
+	keyring, err := NewKeyring("key-1", map[string][]byte{"key-1": testKey1, "key-2": testKey2})
+	require.NoError(t, err)
+	dc := NewEncryptionDataConverter(converter.GetDefaultDataConverter(), DataConverterOptions{KeyProvider: keyring, KeyID: "key-2"})
+	_, err = dc.ToPayload("cached")
+	require.NoError(t, err)
+	require.Len(t, keyring.aeads.entries, 1, "the AEAD is cached on the keyring")
+
+	other, err := NewKeyring("key-1", map[string][]byte{"key-1": testKey1})
+	require.NoError(t, err)
+	require.Empty(t, other.aeads.entries, "each keyring has its own cache")
+
+	require.NoError(t, keyring.ShredKey("key-2"))
+	require.Empty(t, keyring.aeads.entries)
+}
@@ -0,0 +1,108 @@
+package codec
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"testing"
+
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+)
+
+// benchmarkSizes are the payload sizes the codec is benchmarked with
+var benchmarkSizes = []int{100, 1 << 10, 64 << 10, 1 << 20, 2 << 20}
+
+// benchmarkPayload is a json string payload of about size bytes, base64 text compresses like typical json does
+func benchmarkPayload(b *testing.B, size int) *commonpb.Payload {
+	raw := make([]byte, size*3/4)
+	if _, err := rand.Read(raw); err != nil {
+		b.Fatal(err)
+	}
+	p, err := converter.GetDefaultDataConverter().ToPayload(base64.StdEncoding.EncodeToString(raw))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	return p
+}
+
+// benchmarkChain is a codec chain of NewEncryptionDataConverter, applied in the same order
+type benchmarkChain struct {
+	name   string
+	codecs []converter.PayloadCodec
+}
+
+func benchmarkChains(options DataConverterOptions) []benchmarkChain {
+	codec := &Codec{KeyID: "bench", Cipher: options.Cipher, Envelope: options.EnvelopeEncryption}
+	return []benchmarkChain{
+		{"encrypt", []converter.PayloadCodec{codec}},
+		{"zlib+encrypt", []converter.PayloadCodec{codec, converter.NewZlibCodec(converter.ZlibCodecOptions{AlwaysEncode: true})}},
+	}
+}
+
+func encodeChain(codecs []converter.PayloadCodec, payloads []*commonpb.Payload) ([]*commonpb.Payload, error) {
+	var err error
+	for i := len(codecs) - 1; i >= 0; i-- {
+		if payloads, err = codecs[i].Encode(payloads); err != nil {
+			return nil, err
+		}
+	}
+	return payloads, nil
+}
+
+func decodeChain(codecs []converter.PayloadCodec, payloads []*commonpb.Payload) ([]*commonpb.Payload, error) {
+	var err error
+	for _, c := range codecs {
+		if payloads, err = c.Decode(payloads); err != nil {
+			return nil, err
+		}
+	}
+	return payloads, nil
+}
+
+func benchmarkCodec(b *testing.B, options DataConverterOptions) {
+	for _, chain := range benchmarkChains(options) {
+		name, codecs := chain.name, chain.codecs
+		for _, size := range benchmarkSizes {
+			p := benchmarkPayload(b, size)
+
+			b.Run(fmt.Sprintf("%s/%dB/Encode", name, size), func(b *testing.B) {
+				b.SetBytes(int64(size))
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if _, err := encodeChain(codecs, []*commonpb.Payload{p}); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+
+			encoded, err := encodeChain(codecs, []*commonpb.Payload{p})
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.Run(fmt.Sprintf("%s/%dB/Decode", name, size), func(b *testing.B) {
+				b.SetBytes(int64(size))
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					if _, err := decodeChain(codecs, encoded); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+// Run with: go test ./codec -run xxx -bench Codec
+func BenchmarkCodec(b *testing.B) {
+	benchmarkCodec(b, DataConverterOptions{})
+}
+
+func BenchmarkCodecXChaCha20(b *testing.B) {
+	benchmarkCodec(b, DataConverterOptions{Cipher: CipherXChaCha20Poly1305})
+}
+
+func BenchmarkCodecEnvelope(b *testing.B) {
+	benchmarkCodec(b, DataConverterOptions{EnvelopeEncryption: true})
+}
@@ -0,0 +1,81 @@
+package codec
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"go.temporal.io/sdk/converter"
+)
+
+// MetadataEncryptionBinding is "encryption-binding", the json Binding a payload's ciphertext is bound to
+const MetadataEncryptionBinding = "encryption-binding"
+
+const (
+	// BindingRoleWorkflow is for workflow-level payloads: workflow input/result, signals, queries, updates, memo, etc.
+	BindingRoleWorkflow = "workflow"
+
+	// BindingRoleActivity is for activity-level payloads: activity input/result, heartbeat and failure details.
+	BindingRoleActivity = "activity"
+)
+
+// ErrTampered is returned when a bound payload doesn't decrypt in the context it's decoded in.
+// Either it was modified, or it was copied from another namespace, workflow or role.
+var ErrTampered = errors.New("encrypted payload was tampered with or moved")
+
+// Binding is the identity a payload's ciphertext is bound to through the AEAD associated data.
+type Binding struct {
+	Namespace  string `json:"namespace"`
+	WorkflowID string `json:"workflowId"`
+	Role       string `json:"role"`
+}
+
+// bindingFromSerializationContext returns nil for contexts it doesn't know about
+func bindingFromSerializationContext(ctx converter.SerializationContext) *Binding {
+	switch c := ctx.(type) {
+	case converter.WorkflowSerializationContext:
+		return &Binding{Namespace: c.Namespace, WorkflowID: c.WorkflowID, Role: BindingRoleWorkflow}
+	case converter.ActivitySerializationContext:
+		return &Binding{Namespace: c.Namespace, WorkflowID: c.WorkflowID, Role: BindingRoleActivity}
+	default:
+		return nil
+	}
+}
+
+// associatedData is the json encoding of the binding, this is also what's stored in the payload metadata
+func (b *Binding) associatedData() []byte {
+	if b == nil {
+		return nil
+	}
+
+	data, _ := json.Marshal(b) // can't fail for a struct of strings
+	return data
+}
+
+// TamperError is ErrTampered with the binding stored in the payload and the one it was decoded with
+type TamperError struct {
+	Stored  Binding
+	Decoded Binding
+	Err     error
+}
+
+func (e *TamperError) Error() string {
+	return fmt.Sprintf("%v: bound to %+v, decoded in %+v: %v", ErrTampered, e.Stored, e.Decoded, e.Err)
+}
+
+func (e *TamperError) Unwrap() error {
+	return ErrTampered
+}
+
+// WithSerializationContext implements converter.PayloadCodecWithSerializationContext.
+//
+// When BindToWorkflow is set the returned Codec binds payloads to the namespace, workflow ID and role of ctx.
+func (e *Codec) WithSerializationContext(ctx converter.SerializationContext) converter.PayloadCodec {
+	if !e.BindToWorkflow {
+		return e
+	}
+
+	c := *e
+	c.binding = bindingFromSerializationContext(ctx)
+	return &c
+}
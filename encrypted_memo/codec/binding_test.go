@@ -0,0 +1,89 @@
+package codec
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/workflow"
+)
+
+func Test_BindToWorkflow(t *testing.T) {
+	dc := NewEncryptionDataConverter(converter.GetDefaultDataConverter(), DataConverterOptions{BindToWorkflow: true})
+
+	wf1 := dc.WithSerializationContext(converter.WorkflowSerializationContext{Namespace: "default", WorkflowID: "wf-1"})
+	p, err := wf1.ToPayload("bound")
+	require.NoError(t, err)
+	require.JSONEq(t,
+		`{"namespace": "default", "workflowId": "wf-1", "role": "workflow"}`,
+		string(p.GetMetadata()[MetadataEncryptionBinding]),
+	)
+
+	var result string
+	require.NoError(t, wf1.FromPayload(p, &result))
+	require.Equal(t, "bound", result)
+
+	// without a serialization context, e.g. the codec server, the stored binding is used
+	require.NoError(t, dc.FromPayload(p, &result))
+
+	for name, ctx := range map[string]converter.SerializationContext{
+		"other workflow":  converter.WorkflowSerializationContext{Namespace: "default", WorkflowID: "wf-2"},
+		"other namespace": converter.WorkflowSerializationContext{Namespace: "other", WorkflowID: "wf-1"},
+		"other role":      converter.ActivitySerializationContext{Namespace: "default", WorkflowID: "wf-1"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := dc.WithSerializationContext(ctx).FromPayload(p, &result)
+			require.ErrorIs(t, err, ErrTampered)
+
+			var tamperErr *TamperError
+			require.ErrorAs(t, err, &tamperErr)
+			require.Equal(t, "wf-1", tamperErr.Stored.WorkflowID)
+		})
+	}
+
+	// rewriting the stored binding doesn't help, it's not what the ciphertext is verified against
+	p.Metadata[MetadataEncryptionBinding] = []byte(`{"namespace":"default","workflowId":"wf-2","role":"workflow"}`)
+	wf2 := dc.WithSerializationContext(converter.WorkflowSerializationContext{Namespace: "default", WorkflowID: "wf-2"})
+	require.ErrorIs(t, wf2.FromPayload(p, &result), ErrTampered)
+
+	// stripping the binding doesn't help either, the payload doesn't open without it
+	stripped, err := wf1.ToPayload("bound")
+	require.NoError(t, err)
+	delete(stripped.Metadata, MetadataEncryptionBinding)
+	err = wf1.FromPayload(stripped, &result)
+	require.ErrorIs(t, err, ErrTampered)
+	var tamperErr *TamperError
+	require.ErrorAs(t, err, &tamperErr)
+	require.Equal(t, Binding{}, tamperErr.Stored)
+	require.Equal(t, "wf-1", tamperErr.Decoded.WorkflowID)
+
+	// unbound payloads still decode in a bound context
+	unbound, err := NewEncryptionDataConverter(converter.GetDefaultDataConverter(), DataConverterOptions{}).ToPayload("unbound")
+	require.NoError(t, err)
+	require.NoError(t, wf1.FromPayload(unbound, &result))
+	require.Equal(t, "unbound", result)
+}
+
+func Test_BindToWorkflowWithTenant(t *testing.T) {
+	dc := NewEncryptionDataConverter(converter.GetDefaultDataConverter(), DataConverterOptions{BindToWorkflow: true})
+
+	// the SDK sets the serialization context before switching to the propagated tenant
+	ctx := context.WithValue(context.Background(), PropagatedValuesKey, PropagatedValues{TenantID: "tenant-1"})
+	bound := dc.WithSerializationContext(converter.WorkflowSerializationContext{Namespace: "default", WorkflowID: "wf-1"})
+	tenantDC := bound.(workflow.ContextAware).WithContext(ctx)
+
+	p, err := tenantDC.ToPayload("bound")
+	require.NoError(t, err)
+	require.Equal(t, "tenant-1", string(p.GetMetadata()[MetadataEncryptionKeyID]))
+	require.JSONEq(t,
+		`{"namespace": "default", "workflowId": "wf-1", "role": "workflow"}`,
+		string(p.GetMetadata()[MetadataEncryptionBinding]),
+	)
+
+	var result string
+	require.NoError(t, tenantDC.FromPayload(p, &result))
+	require.Equal(t, "bound", result)
+	other := dc.WithSerializationContext(converter.WorkflowSerializationContext{Namespace: "default", WorkflowID: "wf-2"})
+	require.ErrorIs(t, other.FromPayload(p, &result), ErrTampered)
+}
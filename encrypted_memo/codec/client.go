@@ -0,0 +1,180 @@
+package codec
+
+import (
+	"context"
+	"fmt"
+
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/api/enums/v1"
+	failurepb "go.temporal.io/api/failure/v1"
+	historypb "go.temporal.io/api/history/v1"
+	"go.temporal.io/api/proxy"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"google.golang.org/protobuf/proto"
+)
+
+// DecodingClient is a client.Client for tools and dashboards, which runs the payloads of Describe, List,
+// GetWorkflowHistory and query results through a chain of codecs. Memos, search attributes, heartbeat details
+// and failure details come back as plain payloads, readable with the default DataConverter, e.g. with memo.GetAll.
+// Failure messages and stack traces encrypted by NewFailureConverter are restored.
+//
+// The other methods are those of the wrapped client.
+type DecodingClient struct {
+	client.Client
+	codecs []converter.PayloadCodec
+}
+
+var _ client.Client = (*DecodingClient)(nil) // ensure interface is implemented
+
+// NewDecodingClient wraps c to decode through codecs, in the order converter.NewPayloadCodecHTTPHandler takes them.
+// Wrap them in a FailsafeCodec to get placeholders instead of errors for payloads that can't be decoded.
+func NewDecodingClient(c client.Client, codecs ...converter.PayloadCodec) *DecodingClient {
+	return &DecodingClient{Client: c, codecs: codecs}
+}
+
+// decode decodes the payloads of msg in place, and restores the failure messages and stack traces moved into
+// encoded attributes by a FailureConverter
+func (c *DecodingClient) decode(ctx context.Context, msg proto.Message) error {
+	err := proxy.VisitPayloads(ctx, msg, proxy.VisitPayloadsOptions{
+		Visitor: func(_ *proxy.VisitPayloadsContext, payloads []*commonpb.Payload) ([]*commonpb.Payload, error) {
+			var err error
+			for _, codec := range c.codecs {
+				if payloads, err = codec.Decode(payloads); err != nil {
+					return nil, err
+				}
+			}
+			return payloads, nil
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return proxy.VisitFailures(ctx, msg, proxy.VisitFailuresOptions{
+		Visitor: func(_ *proxy.VisitFailuresContext, f *failurepb.Failure) error {
+			metadata := f.GetEncodedAttributes().GetMetadata()
+			// placeholders of a FailsafeCodec or a shredded key have no message, keep "Encoded failure"
+			if metadata[MetadataEncryptionDecodeError] == nil && metadata[MetadataEncryptionRedacted] == nil {
+				converter.DecodeCommonFailureAttributes(converter.GetDefaultDataConverter(), f)
+			}
+			return nil
+		},
+	})
+}
+
+// DescribeWorkflowExecution returns the description with its memo, search attributes,
+// pending activity heartbeat details and failures decoded
+func (c *DecodingClient) DescribeWorkflowExecution(ctx context.Context, workflowID, runID string) (*workflowservice.DescribeWorkflowExecutionResponse, error) {
+	resp, err := c.Client.DescribeWorkflowExecution(ctx, workflowID, runID)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.decode(ctx, resp); err != nil {
+		return nil, fmt.Errorf("failed to decode workflow description: %w", err)
+	}
+
+	return resp, nil
+}
+
+// ListWorkflow returns the executions with their memos and search attributes decoded
+func (c *DecodingClient) ListWorkflow(ctx context.Context, request *workflowservice.ListWorkflowExecutionsRequest) (*workflowservice.ListWorkflowExecutionsResponse, error) {
+	resp, err := c.Client.ListWorkflow(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.decode(ctx, resp); err != nil {
+		return nil, fmt.Errorf("failed to decode workflow executions: %w", err)
+	}
+
+	return resp, nil
+}
+
+// GetWorkflowHistory returns an iterator of the history events with their payloads decoded
+func (c *DecodingClient) GetWorkflowHistory(ctx context.Context, workflowID string, runID string, isLongPoll bool, filterType enums.HistoryEventFilterType) client.HistoryEventIterator {
+	return &decodingHistoryIterator{
+		ctx:      ctx,
+		client:   c,
+		iterator: c.Client.GetWorkflowHistory(ctx, workflowID, runID, isLongPoll, filterType),
+	}
+}
+
+// QueryWorkflow returns the query result decoded
+func (c *DecodingClient) QueryWorkflow(ctx context.Context, workflowID string, runID string, queryType string, args ...interface{}) (converter.EncodedValue, error) {
+	value, err := c.Client.QueryWorkflow(ctx, workflowID, runID, queryType, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.decodeValue(ctx, value)
+}
+
+// QueryWorkflowWithOptions returns the query result decoded
+func (c *DecodingClient) QueryWorkflowWithOptions(ctx context.Context, request *client.QueryWorkflowWithOptionsRequest) (*client.QueryWorkflowWithOptionsResponse, error) {
+	resp, err := c.Client.QueryWorkflowWithOptions(ctx, request)
+	if err != nil || resp.QueryResult == nil {
+		return resp, err
+	}
+
+	if resp.QueryResult, err = c.decodeValue(ctx, resp.QueryResult); err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// decodeValue decodes the payload of an EncodedValue.
+// Reading it into a RawValue gets the payload as is, or as decoded by the wrapped client's DataConverter.
+func (c *DecodingClient) decodeValue(ctx context.Context, value converter.EncodedValue) (converter.EncodedValue, error) {
+	if !value.HasValue() {
+		return value, nil
+	}
+
+	var raw converter.RawValue
+	if err := value.Get(&raw); err != nil {
+		return nil, fmt.Errorf("failed to read query result: %w", err)
+	}
+	payloads := &commonpb.Payloads{Payloads: []*commonpb.Payload{raw.Payload()}}
+	if err := c.decode(ctx, payloads); err != nil {
+		return nil, fmt.Errorf("failed to decode query result: %w", err)
+	}
+
+	return decodedValue{payload: payloads.Payloads[0]}, nil
+}
+
+// decodedValue is a converter.EncodedValue of a decoded payload, read with the default DataConverter
+type decodedValue struct {
+	payload *commonpb.Payload
+}
+
+func (v decodedValue) HasValue() bool {
+	return v.payload != nil
+}
+
+func (v decodedValue) Get(valuePtr interface{}) error {
+	return converter.GetDefaultDataConverter().FromPayload(v.payload, valuePtr)
+}
+
+// decodingHistoryIterator decodes each event as it's read
+type decodingHistoryIterator struct {
+	ctx      context.Context
+	client   *DecodingClient
+	iterator client.HistoryEventIterator
+}
+
+func (i *decodingHistoryIterator) HasNext() bool {
+	return i.iterator.HasNext()
+}
+
+func (i *decodingHistoryIterator) Next() (*historypb.HistoryEvent, error) {
+	event, err := i.iterator.Next()
+	if err != nil {
+		return nil, err
+	}
+	if err := i.client.decode(i.ctx, event); err != nil {
+		return nil, fmt.Errorf("failed to decode event %d: %w", event.GetEventId(), err)
+	}
+
+	return event, nil
+}
@@ -0,0 +1,111 @@
+package codec
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/api/enums/v1"
+	historypb "go.temporal.io/api/history/v1"
+	workflowpb "go.temporal.io/api/workflow/v1"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/mocks"
+)
+
+func Test_DecodingClient(t *testing.T) {
+	dc := NewEncryptionDataConverter(converter.GetDefaultDataConverter(), DataConverterOptions{Compress: true})
+	encrypted := func(value interface{}) *commonpb.Payload {
+		p, err := dc.ToPayload(value)
+		require.NoError(t, err)
+		require.Equal(t, MetadataEncodingEncrypted, string(p.GetMetadata()[converter.MetadataEncoding]))
+		return p
+	}
+	decoded := func(p *commonpb.Payload) string {
+		var s string
+		require.NoError(t, converter.GetDefaultDataConverter().FromPayload(p, &s))
+		return s
+	}
+
+	mockClient := &mocks.Client{}
+	c := NewDecodingClient(mockClient, &Codec{}, converter.NewZlibCodec(converter.ZlibCodecOptions{}))
+	ctx := context.Background()
+
+	mockClient.On("DescribeWorkflowExecution", ctx, "wf", "run").Return(&workflowservice.DescribeWorkflowExecutionResponse{
+		WorkflowExecutionInfo: &workflowpb.WorkflowExecutionInfo{
+			Memo: &commonpb.Memo{Fields: map[string]*commonpb.Payload{"city": encrypted("seattle")}},
+		},
+		PendingActivities: []*workflowpb.PendingActivityInfo{{
+			HeartbeatDetails: &commonpb.Payloads{Payloads: []*commonpb.Payload{encrypted("progress")}},
+		}},
+	}, nil)
+	describe, err := c.DescribeWorkflowExecution(ctx, "wf", "run")
+	require.NoError(t, err)
+	require.Equal(t, "seattle", decoded(describe.GetWorkflowExecutionInfo().GetMemo().GetFields()["city"]))
+	require.Equal(t, "progress", decoded(describe.GetPendingActivities()[0].GetHeartbeatDetails().GetPayloads()[0]))
+
+	mockClient.On("ListWorkflow", ctx, mock.Anything).Return(&workflowservice.ListWorkflowExecutionsResponse{
+		Executions: []*workflowpb.WorkflowExecutionInfo{{
+			Memo: &commonpb.Memo{Fields: map[string]*commonpb.Payload{"city": encrypted("tacoma")}},
+		}},
+	}, nil)
+	list, err := c.ListWorkflow(ctx, &workflowservice.ListWorkflowExecutionsRequest{})
+	require.NoError(t, err)
+	require.Equal(t, "tacoma", decoded(list.GetExecutions()[0].GetMemo().GetFields()["city"]))
+
+	iterator := &mocks.HistoryEventIterator{}
+	iterator.On("HasNext").Return(true).Once()
+	iterator.On("Next").Return(&historypb.HistoryEvent{
+		EventId: 1,
+		Attributes: &historypb.HistoryEvent_WorkflowExecutionStartedEventAttributes{
+			WorkflowExecutionStartedEventAttributes: &historypb.WorkflowExecutionStartedEventAttributes{
+				Input: &commonpb.Payloads{Payloads: []*commonpb.Payload{encrypted("input")}},
+			},
+		},
+	}, nil)
+	mockClient.On("GetWorkflowHistory", ctx, "wf", "run", false, enums.HISTORY_EVENT_FILTER_TYPE_ALL_EVENT).Return(iterator)
+	history := c.GetWorkflowHistory(ctx, "wf", "run", false, enums.HISTORY_EVENT_FILTER_TYPE_ALL_EVENT)
+	require.True(t, history.HasNext())
+	event, err := history.Next()
+	require.NoError(t, err)
+	require.Equal(t, "input", decoded(event.GetWorkflowExecutionStartedEventAttributes().GetInput().GetPayloads()[0]))
+
+	queryResult := encrypted("state")
+	value := &mocks.Value{}
+	value.On("HasValue").Return(true)
+	value.On("Get", mock.Anything).Run(func(args mock.Arguments) {
+		*args.Get(0).(*converter.RawValue) = converter.NewRawValue(queryResult)
+	}).Return(nil)
+	mockClient.On("QueryWorkflow", ctx, "wf", "run", "state").Return(value, nil)
+	result, err := c.QueryWorkflow(ctx, "wf", "run", "state")
+	require.NoError(t, err)
+	var state string
+	require.NoError(t, result.Get(&state))
+	require.Equal(t, "state", state)
+}
+
+func Test_DecodingClientError(t *testing.T) {
+	keyring, err := NewKeyring("other", map[string][]byte{"other": testKey2})
+	require.NoError(t, err)
+	p, err := (&Codec{}).Encode([]*commonpb.Payload{{Data: []byte("secret")}})
+	require.NoError(t, err)
+
+	mockClient := &mocks.Client{}
+	ctx := context.Background()
+	mockClient.On("DescribeWorkflowExecution", ctx, "wf", "run").Return(&workflowservice.DescribeWorkflowExecutionResponse{
+		WorkflowExecutionInfo: &workflowpb.WorkflowExecutionInfo{
+			Memo: &commonpb.Memo{Fields: map[string]*commonpb.Payload{"secret": p[0]}},
+		},
+	}, nil)
+
+	_, err = NewDecodingClient(mockClient, &Codec{KeyProvider: keyring}).DescribeWorkflowExecution(ctx, "wf", "run")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+
+	// with a FailsafeCodec the payload is swapped for a placeholder
+	resp, err := NewDecodingClient(mockClient, NewFailsafeCodec(&Codec{KeyProvider: keyring})).DescribeWorkflowExecution(ctx, "wf", "run")
+	require.NoError(t, err)
+	require.Equal(t, ErrorClassKeyNotFound,
+		string(resp.GetWorkflowExecutionInfo().GetMemo().GetFields()["secret"].GetMetadata()[MetadataEncryptionDecodeError]))
+}
@@ -4,45 +4,78 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
+	"errors"
 	"fmt"
 	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
 )
 
-func encrypt(plainData []byte, key []byte) ([]byte, error) {
-	c, err := aes.NewCipher(key)
-	if err != nil {
-		return nil, err
-	}
+// MetadataEncryptionCipher is "encryption-cipher", the Cipher a payload is encrypted with.
+// Payloads without it were encrypted with CipherAES256GCM.
+const MetadataEncryptionCipher = "encryption-cipher"
 
-	gcm, err := cipher.NewGCM(c)
-	if err != nil {
-		return nil, err
-	}
+// ErrDecryptionFailed is returned for ciphertext that doesn't open with the key, it's corrupt or the key is wrong
+var ErrDecryptionFailed = errors.New("failed to decrypt payload")
 
-	nonce := make([]byte, gcm.NonceSize())
-	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
-		return nil, err
-	}
+// Cipher is the AEAD algorithm payloads are encrypted with, all of them take a 32 byte key
+type Cipher string
 
-	return gcm.Seal(nonce, nonce, plainData, nil), nil
-}
+const (
+	// CipherAES256GCM is AES-256-GCM with a random 96-bit nonce, the default.
+	CipherAES256GCM Cipher = "AES-256-GCM"
 
-func decrypt(encryptedData []byte, key []byte) ([]byte, error) {
-	c, err := aes.NewCipher(key)
-	if err != nil {
-		return nil, err
+	// CipherChaCha20Poly1305 is ChaCha20-Poly1305 with a random 96-bit nonce,
+	// faster than AES-GCM on hardware without AES instructions.
+	CipherChaCha20Poly1305 Cipher = "CHACHA20-POLY1305"
+
+	// CipherXChaCha20Poly1305 is XChaCha20-Poly1305 with a random 192-bit nonce.
+	// Random 96-bit nonces should not be used for more than 2^32 messages per key, 192-bit nonces are safe
+	// at any volume.
+	CipherXChaCha20Poly1305 Cipher = "XCHACHA20-POLY1305"
+)
+
+// newAEAD returns the cipher.AEAD of c for key, an empty Cipher is CipherAES256GCM
+func newAEAD(c Cipher, key []byte) (cipher.AEAD, error) {
+	switch c {
+	case "", CipherAES256GCM:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		return cipher.NewGCM(block)
+	case CipherChaCha20Poly1305:
+		return chacha20poly1305.New(key)
+	case CipherXChaCha20Poly1305:
+		return chacha20poly1305.NewX(key)
+	default:
+		return nil, fmt.Errorf("unsupported cipher %q", c)
 	}
+}
 
-	gcm, err := cipher.NewGCM(c)
-	if err != nil {
+// seal encrypts plainData with a random nonce, which is prepended to the ciphertext.
+// additionalData is authenticated but not encrypted and may be nil.
+func seal(aead cipher.AEAD, plainData []byte, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plainData)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
 		return nil, err
 	}
 
-	nonceSize := gcm.NonceSize()
+	return aead.Seal(nonce, nonce, plainData, additionalData), nil
+}
+
+// open decrypts data sealed by seal, the additionalData must match what it was sealed with
+func open(aead cipher.AEAD, encryptedData []byte, additionalData []byte) ([]byte, error) {
+	nonceSize := aead.NonceSize()
 	if len(encryptedData) < nonceSize {
-		return nil, fmt.Errorf("ciphertext too short: %v", encryptedData)
+		return nil, fmt.Errorf("%w: ciphertext too short: %v", ErrDecryptionFailed, encryptedData)
 	}
 
 	nonce, encryptedData := encryptedData[:nonceSize], encryptedData[nonceSize:]
-	return gcm.Open(nil, nonce, encryptedData, nil)
+	plainData, err := aead.Open(nil, nonce, encryptedData, additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecryptionFailed, err)
+	}
+
+	return plainData, nil
 }
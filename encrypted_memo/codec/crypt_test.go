@@ -0,0 +1,56 @@
+package codec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/converter"
+)
+
+func Test_Ciphers(t *testing.T) {
+	for _, c := range []Cipher{CipherAES256GCM, CipherChaCha20Poly1305, CipherXChaCha20Poly1305} {
+		t.Run(string(c), func(t *testing.T) {
+			for _, envelope := range []bool{false, true} {
+				dc := NewEncryptionDataConverter(converter.GetDefaultDataConverter(), DataConverterOptions{
+					Cipher:             c,
+					EnvelopeEncryption: envelope,
+				})
+
+				p, err := dc.ToPayload("secret")
+				require.NoError(t, err)
+				require.Equal(t, string(c), string(p.GetMetadata()[MetadataEncryptionCipher]))
+				require.NotContains(t, string(p.GetData()), "secret")
+
+				var result string
+				require.NoError(t, dc.FromPayload(p, &result))
+				require.Equal(t, "secret", result)
+			}
+		})
+	}
+}
+
+func Test_CipherMigration(t *testing.T) {
+	aes := NewEncryptionDataConverter(converter.GetDefaultDataConverter(), DataConverterOptions{})
+	xchacha := NewEncryptionDataConverter(converter.GetDefaultDataConverter(), DataConverterOptions{
+		Cipher: CipherXChaCha20Poly1305,
+	})
+
+	old, err := aes.ToPayload("old")
+	require.NoError(t, err)
+	require.Equal(t, string(CipherAES256GCM), string(old.GetMetadata()[MetadataEncryptionCipher]))
+
+	// payloads from before the cipher was recorded are AES-256-GCM
+	delete(old.Metadata, MetadataEncryptionCipher)
+
+	current, err := xchacha.ToPayload("new")
+	require.NoError(t, err)
+
+	// the cipher is picked per payload, so a converter decodes both
+	var a, b string
+	require.NoError(t, xchacha.FromPayload(old, &a))
+	require.NoError(t, xchacha.FromPayload(current, &b))
+	require.Equal(t, []string{"old", "new"}, []string{a, b})
+
+	current.Metadata[MetadataEncryptionCipher] = []byte("ROT13")
+	require.ErrorContains(t, xchacha.FromPayload(current, &b), `unsupported cipher "ROT13"`)
+}
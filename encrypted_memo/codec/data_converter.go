@@ -1,11 +1,16 @@
 package codec
 
 import (
+	"context"
+	"crypto/cipher"
+	"encoding/json"
+	"errors"
 	"fmt"
 
 	commonpb "go.temporal.io/api/common/v1"
 
 	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/workflow"
 )
 
 const (
@@ -14,8 +19,18 @@ const (
 
 	// MetadataEncryptionKeyID is "encryption-key-id"
 	MetadataEncryptionKeyID = "encryption-key-id"
+
+	// MetadataEncryptionFormat is "encryption-format", the version of the binary/encrypted layout, see FORMAT.md.
+	// Payloads without it are EncryptionFormatV1, like those of earlier versions and of the TypeScript and Python samples.
+	MetadataEncryptionFormat = "encryption-format"
+
+	// EncryptionFormatV1 is the nonce prefixed AEAD sealed, serialized Payload
+	EncryptionFormatV1 = "1"
 )
 
+// ErrUnsupportedFormat is returned for payloads in an encryption format this version can't decode
+var ErrUnsupportedFormat = errors.New("unsupported encryption format")
+
 var DefaultEncryptionCodec = NewEncryptionDataConverter(
 	converter.GetDefaultDataConverter(),
 	DataConverterOptions{Compress: true},
@@ -26,33 +41,123 @@ type DataConverter struct {
 	parent converter.DataConverter
 	converter.DataConverter
 	options DataConverterOptions
+
+	// serializationContext is reapplied when WithContext switches to the key of a tenant,
+	// the SDK sets it before calling WithContext
+	serializationContext converter.SerializationContext
 }
 
 // make sure this matches this
 var _ converter.DataConverter = (*DataConverter)(nil)
+var _ workflow.ContextAware = (*DataConverter)(nil)
 
 type DataConverterOptions struct {
+	// KeyID to encrypt with, when empty the active key of an ActiveKeyProvider is used.
 	KeyID string
+	// KeyProvider resolves key IDs to keys, defaults to a hard coded test key.
+	KeyProvider KeyProvider
 	// Enable ZLib compression before encryption.
 	Compress bool
+	// Cipher to encrypt with, defaults to CipherAES256GCM. Payloads are decrypted with the cipher they record.
+	Cipher Cipher
+	// EnvelopeEncryption encrypts each batch of payloads with a random data key, which is wrapped by the master key.
+	EnvelopeEncryption bool
+	// BindToWorkflow binds the ciphertext to the namespace, workflow ID and payload role it was encrypted for.
+	// A payload copied into another workflow or namespace fails to decrypt with ErrTampered.
+	BindToWorkflow bool
+	// TenantKeyID picks the KeyID for the tenant propagated by NewContextPropagator, defaults to the tenant ID.
+	TenantKeyID func(tenantID string) string
 }
 
 // Codec implements PayloadCodec using AES Crypt.
 type Codec struct {
+	// KeyID to encrypt with, when empty the active key of an ActiveKeyProvider is used.
 	KeyID string
+	// KeyProvider resolves key IDs to keys, defaults to a hard coded test key.
+	KeyProvider KeyProvider
+	// Cipher to encrypt with, defaults to CipherAES256GCM.
+	// It's recorded in the payload metadata under MetadataEncryptionCipher so Decode can migrate between ciphers.
+	Cipher Cipher
+	// Envelope encrypts each batch of payloads with a random data key, which is wrapped by the master key.
+	// The wrapped data key is stored in the payload metadata under MetadataEncryptionDataKey.
+	Envelope bool
+	// BindToWorkflow binds the ciphertext to the Binding from the SDK's serialization context,
+	// through the AEAD associated data. See WithSerializationContext.
+	BindToWorkflow bool
+
+	binding *Binding   // set by WithSerializationContext
+	aeads   *aeadCache // set by NewCodec for the hard coded test key, other KeyProviders hold their own
 }
 
-func (e *Codec) getKey(keyID string) (key []byte) {
+func (e *Codec) getKey(keyID string) ([]byte, error) {
 	// Key must be fetched from secure storage in production (such as a KMS).
-	// For testing here we just hard code a key.
-	return []byte("test-key-test-key-test-key-test!")
+	// For testing, without a KeyProvider, we just use a hard coded key.
+	if e.KeyProvider == nil {
+		return testKeyProvider{}.GetKey(keyID)
+	}
+
+	return e.KeyProvider.GetKey(keyID)
+}
+
+// aeadCache is the AEAD cache of the KeyProvider, so shredding a key clears it, nil doesn't cache
+func (e *Codec) aeadCache() *aeadCache {
+	if c, ok := e.KeyProvider.(aeadCacher); ok {
+		return c.aeadCache()
+	}
+
+	return e.aeads
+}
+
+// cipher is the Cipher new payloads are encrypted with
+func (e *Codec) cipher() Cipher {
+	if e.Cipher == "" {
+		return CipherAES256GCM
+	}
+
+	return e.Cipher
+}
+
+// encryptKeyID is the key new payloads are encrypted with
+func (e *Codec) encryptKeyID() string {
+	if e.KeyID != "" {
+		return e.KeyID
+	}
+	if p, ok := e.KeyProvider.(ActiveKeyProvider); ok {
+		return p.ActiveKeyID()
+	}
+
+	return ""
 }
 
 // NewEncryptionDataConverter creates a new instance of EncryptionDataConverter wrapping a DataConverter
 func NewEncryptionDataConverter(dataConverter converter.DataConverter, options DataConverterOptions) *DataConverter {
-	codecs := []converter.PayloadCodec{
-		&Codec{KeyID: options.KeyID},
+	return &DataConverter{
+		parent:        dataConverter,
+		DataConverter: converter.NewCodecDataConverter(dataConverter, NewPayloadCodecs(options)...),
+		options:       options,
+	}
+}
+
+// NewCodec returns the Codec a DataConverter with options encrypts with
+func NewCodec(options DataConverterOptions) *Codec {
+	c := &Codec{
+		KeyID:          options.KeyID,
+		KeyProvider:    options.KeyProvider,
+		Cipher:         options.Cipher,
+		Envelope:       options.EnvelopeEncryption,
+		BindToWorkflow: options.BindToWorkflow,
+	}
+	if options.KeyProvider == nil {
+		c.aeads = &aeadCache{}
 	}
+
+	return c
+}
+
+// NewPayloadCodecs returns the codecs of a DataConverter with options, in the order
+// converter.NewPayloadCodecHTTPHandler takes them, e.g. for a codec server
+func NewPayloadCodecs(options DataConverterOptions) []converter.PayloadCodec {
+	codecs := []converter.PayloadCodec{NewCodec(options)}
 	// Enable compression if requested.
 	// Note that this must be done before encryption to provide any value. Encrypted data should by design not compress very well.
 	// This means the compression codec must come after the encryption codec here as codecs are applied last -> first.
@@ -60,15 +165,104 @@ func NewEncryptionDataConverter(dataConverter converter.DataConverter, options D
 		codecs = append(codecs, converter.NewZlibCodec(converter.ZlibCodecOptions{AlwaysEncode: true}))
 	}
 
+	return codecs
+}
+
+// WithContext encrypts with the key of the tenant propagated in ctx, see NewContextPropagator.
+//
+// This is called when payloads needs to be passed between the Clients/Activity and the Temporal Server.
+func (dc *DataConverter) WithContext(ctx context.Context) converter.DataConverter {
+	if vals, ok := ctx.Value(PropagatedValuesKey).(PropagatedValues); ok {
+		parent := dc.parent
+		if parentWithContext, ok := parent.(workflow.ContextAware); ok {
+			parent = parentWithContext.WithContext(ctx)
+		}
+
+		return dc.withTenant(parent, vals.TenantID)
+	}
+
+	return dc
+}
+
+// WithWorkflowContext encrypts with the key of the tenant propagated in ctx, see NewContextPropagator.
+//
+// This is called inside the Workflow to encode/decode the Workflow Input and Result, Activity Input, etc.
+func (dc *DataConverter) WithWorkflowContext(ctx workflow.Context) converter.DataConverter {
+	if vals, ok := ctx.Value(PropagatedValuesKey).(PropagatedValues); ok {
+		parent := dc.parent
+		if parentWithContext, ok := parent.(workflow.ContextAware); ok {
+			parent = parentWithContext.WithWorkflowContext(ctx)
+		}
+
+		return dc.withTenant(parent, vals.TenantID)
+	}
+
+	return dc
+}
+
+// withTenant returns a DataConverter of parent encrypting with the key of the tenant, in the same serialization context
+func (dc *DataConverter) withTenant(parent converter.DataConverter, tenantID string) converter.DataConverter {
+	tenantDC := NewEncryptionDataConverter(parent, dc.tenantOptions(tenantID))
+	if dc.serializationContext != nil {
+		return tenantDC.WithSerializationContext(dc.serializationContext)
+	}
+
+	return tenantDC
+}
+
+// tenantOptions are the options with the KeyID of the tenant, decoding still uses the key ID in the payload
+func (dc *DataConverter) tenantOptions(tenantID string) DataConverterOptions {
+	options := dc.options
+	if tenantID == "" {
+		return options
+	}
+
+	options.KeyID = tenantID
+	if options.TenantKeyID != nil {
+		options.KeyID = options.TenantKeyID(tenantID)
+	}
+
+	return options
+}
+
+// WithSerializationContext implements converter.DataConverterWithSerializationContext.
+// The SDK calls it with the namespace and workflow ID payloads are converted for, see Codec.BindToWorkflow.
+func (dc *DataConverter) WithSerializationContext(ctx converter.SerializationContext) converter.DataConverter {
 	return &DataConverter{
-		parent:        dataConverter,
-		DataConverter: converter.NewCodecDataConverter(dataConverter, codecs...),
-		options:       options,
+		parent:               dc.parent,
+		DataConverter:        converter.WithDataConverterSerializationContext(dc.DataConverter, ctx),
+		options:              dc.options,
+		serializationContext: ctx,
 	}
 }
 
 // Encode implements converter.PayloadCodec.Encode.
 func (e *Codec) Encode(payloads []*commonpb.Payload) ([]*commonpb.Payload, error) {
+	keyID := e.encryptKeyID()
+	c := e.cipher()
+
+	// with envelope encryption the whole batch shares one data key, so there's one wrap call per batch
+	var aead cipher.AEAD
+	var wrappedKey []byte
+	if e.Envelope {
+		dataKey, wrapped, err := e.newDataKey(keyID)
+		if err != nil {
+			return payloads, err
+		}
+		wrappedKey = wrapped
+		if aead, err = newAEAD(c, dataKey); err != nil {
+			return payloads, err
+		}
+	} else {
+		key, err := e.getKey(keyID)
+		if err != nil {
+			return payloads, err
+		}
+		if aead, err = e.aeadCache().get(c, keyID, key); err != nil {
+			return payloads, err
+		}
+	}
+
 	result := make([]*commonpb.Payload, len(payloads))
 	for i, p := range payloads {
 		origBytes, err := p.Marshal()
@@ -76,9 +270,7 @@ func (e *Codec) Encode(payloads []*commonpb.Payload) ([]*commonpb.Payload, error
 			return payloads, err
 		}
 
-		key := e.getKey(e.KeyID)
-
-		b, err := encrypt(origBytes, key)
+		b, err := seal(aead, origBytes, e.binding.associatedData())
 		if err != nil {
 			return payloads, err
 		}
@@ -86,17 +278,34 @@ func (e *Codec) Encode(payloads []*commonpb.Payload) ([]*commonpb.Payload, error
 		result[i] = &commonpb.Payload{
 			Metadata: map[string][]byte{
 				converter.MetadataEncoding: []byte(MetadataEncodingEncrypted),
-				MetadataEncryptionKeyID:    []byte(e.KeyID),
+				MetadataEncryptionFormat:   []byte(EncryptionFormatV1),
+				MetadataEncryptionKeyID:    []byte(keyID),
+				MetadataEncryptionCipher:   []byte(c),
 			},
 			Data: b,
 		}
+		if wrappedKey != nil {
+			result[i].Metadata[MetadataEncryptionDataKey] = wrappedKey
+		}
+		if e.binding != nil {
+			result[i].Metadata[MetadataEncryptionBinding] = e.binding.associatedData()
+		}
 	}
 
 	return result, nil
 }
 
 // Decode implements converter.PayloadCodec.Decode.
+// Payloads encrypted with a shredded key decode to a RedactedPayload placeholder, see KeyShredder.
 func (e *Codec) Decode(payloads []*commonpb.Payload) ([]*commonpb.Payload, error) {
+	// keys are looked up, or data keys unwrapped, once per batch
+	type batchKey struct {
+		cipher     Cipher
+		keyID      string
+		wrappedKey string
+	}
+	aeads := map[batchKey]cipher.AEAD{}
+
 	result := make([]*commonpb.Payload, len(payloads))
 	for i, p := range payloads {
 		// Only if it's encrypted
@@ -105,14 +314,72 @@ func (e *Codec) Decode(payloads []*commonpb.Payload) ([]*commonpb.Payload, error
 			continue
 		}
 
+		if format, ok := p.Metadata[MetadataEncryptionFormat]; ok && string(format) != EncryptionFormatV1 {
+			return payloads, fmt.Errorf("%w: %q", ErrUnsupportedFormat, format)
+		}
+
 		keyID, ok := p.Metadata[MetadataEncryptionKeyID]
 		if !ok {
 			return payloads, fmt.Errorf("no encryption key id")
 		}
 
-		key := e.getKey(string(keyID))
+		// payloads from before the cipher was recorded are AES-256-GCM
+		c := Cipher(p.Metadata[MetadataEncryptionCipher])
+		if c == "" {
+			c = CipherAES256GCM
+		}
+
+		wrappedKey, envelope := p.Metadata[MetadataEncryptionDataKey]
+		cacheKey := batchKey{cipher: c, keyID: string(keyID), wrappedKey: string(wrappedKey)}
+		aead, ok := aeads[cacheKey]
+		if !ok {
+			var key []byte
+			var err error
+			if envelope {
+				key, err = e.unwrapDataKey(c, string(keyID), wrappedKey)
+			} else {
+				key, err = e.getKey(string(keyID))
+			}
+			// payloads of a shredded key are redacted so the rest of the history can still be decoded
+			// the key may have been shredded by another process, e.g. key-shred, its AEADs are dropped too
+			var shredded *KeyShreddedError
+			if errors.As(err, &shredded) {
+				e.aeadCache().forget(string(keyID))
+				result[i] = shredded.payload(string(keyID))
+				continue
+			}
+			if err != nil {
+				return payloads, err
+			}
+			if envelope {
+				aead, err = newAEAD(c, key)
+			} else {
+				aead, err = e.aeadCache().get(c, string(keyID), key)
+			}
+			if err != nil {
+				return payloads, err
+			}
+			aeads[cacheKey] = aead
+		}
 
-		b, err := decrypt(p.Data, key)
+		// Bound payloads are verified against the binding of the context they're decoded in.
+		// Without a context, e.g. in the codec server, the stored binding is used.
+		stored, bound := p.Metadata[MetadataEncryptionBinding]
+		additionalData := stored
+		if bound && e.binding != nil {
+			additionalData = e.binding.associatedData()
+		}
+
+		// When a binding is expected, a payload that doesn't open was tampered with, including one whose binding
+		// metadata was stripped. Unbound payloads written before BindToWorkflow was enabled still open.
+		b, err := open(aead, p.Data, additionalData)
+		if err != nil && e.binding != nil {
+			tamperErr := &TamperError{Decoded: *e.binding, Err: err}
+			if bound {
+				_ = json.Unmarshal(stored, &tamperErr.Stored)
+			}
+			return payloads, tamperErr
+		}
 		if err != nil {
 			return payloads, err
 		}
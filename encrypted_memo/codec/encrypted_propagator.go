@@ -0,0 +1,124 @@
+package codec
+
+import (
+	"context"
+
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/workflow"
+)
+
+// encryptedPropagator runs the headers of a wrapped propagator through a PayloadCodec
+type encryptedPropagator struct {
+	propagator workflow.ContextPropagator
+	codec      converter.PayloadCodec
+}
+
+// NewEncryptedContextPropagator wraps a context propagator so the headers it writes are encoded with codec,
+// e.g. a Codec, and decoded before it reads them. Headers are stored in the history through the default
+// DataConverter, without this tenant or user identifiers are visible in every event.
+//
+// Headers written before the propagator was wrapped aren't encrypted and are still read.
+func NewEncryptedContextPropagator(propagator workflow.ContextPropagator, codec converter.PayloadCodec) workflow.ContextPropagator {
+	return &encryptedPropagator{propagator: propagator, codec: codec}
+}
+
+// Inject injects the encoded values from context into headers for propagation
+func (p *encryptedPropagator) Inject(ctx context.Context, writer workflow.HeaderWriter) error {
+	headers := &headerBuffer{}
+	if err := p.propagator.Inject(ctx, headers); err != nil {
+		return err
+	}
+	return headers.flush(p.codec, writer)
+}
+
+// InjectFromWorkflow injects the encoded values from context into headers for propagation
+func (p *encryptedPropagator) InjectFromWorkflow(ctx workflow.Context, writer workflow.HeaderWriter) error {
+	headers := &headerBuffer{}
+	if err := p.propagator.InjectFromWorkflow(ctx, headers); err != nil {
+		return err
+	}
+	return headers.flush(p.codec, writer)
+}
+
+// Extract decodes the headers and extracts values from them into context
+func (p *encryptedPropagator) Extract(ctx context.Context, reader workflow.HeaderReader) (context.Context, error) {
+	headers := &decodingHeaderReader{reader: reader, codec: p.codec}
+	ctx, err := p.propagator.Extract(ctx, headers)
+	if err == nil {
+		err = headers.err
+	}
+	return ctx, err
+}
+
+// ExtractToWorkflow decodes the headers and extracts values from them into context
+func (p *encryptedPropagator) ExtractToWorkflow(ctx workflow.Context, reader workflow.HeaderReader) (workflow.Context, error) {
+	headers := &decodingHeaderReader{reader: reader, codec: p.codec}
+	ctx, err := p.propagator.ExtractToWorkflow(ctx, headers)
+	if err == nil {
+		err = headers.err
+	}
+	return ctx, err
+}
+
+// headerBuffer collects the headers of an Inject, so they're encoded as one batch
+type headerBuffer struct {
+	keys     []string
+	payloads []*commonpb.Payload
+}
+
+func (h *headerBuffer) Set(key string, value *commonpb.Payload) {
+	h.keys = append(h.keys, key)
+	h.payloads = append(h.payloads, value)
+}
+
+func (h *headerBuffer) flush(codec converter.PayloadCodec, writer workflow.HeaderWriter) error {
+	if len(h.payloads) == 0 {
+		return nil
+	}
+
+	encoded, err := codec.Encode(h.payloads)
+	if err != nil {
+		return err
+	}
+	for i, key := range h.keys {
+		writer.Set(key, encoded[i])
+	}
+
+	return nil
+}
+
+// decodingHeaderReader decodes headers as they're read.
+// HeaderReader can't return errors, the first one is kept in err and returned by Extract.
+type decodingHeaderReader struct {
+	reader workflow.HeaderReader
+	codec  converter.PayloadCodec
+	err    error
+}
+
+func (h *decodingHeaderReader) Get(key string) (*commonpb.Payload, bool) {
+	value, ok := h.reader.Get(key)
+	if !ok {
+		return nil, false
+	}
+
+	decoded, err := h.codec.Decode([]*commonpb.Payload{value})
+	if err != nil {
+		if h.err == nil {
+			h.err = err
+		}
+		return nil, false
+	}
+
+	return decoded[0], true
+}
+
+func (h *decodingHeaderReader) ForEachKey(handler func(string, *commonpb.Payload) error) error {
+	return h.reader.ForEachKey(func(key string, value *commonpb.Payload) error {
+		decoded, err := h.codec.Decode([]*commonpb.Payload{value})
+		if err != nil {
+			return err
+		}
+		return handler(key, decoded[0])
+	})
+}
@@ -0,0 +1,103 @@
+package codec
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+)
+
+// MetadataEncryptionDataKey is "encryption-data-key", the wrapped data key of an envelope encrypted payload.
+// MetadataEncryptionKeyID is the key the data key is wrapped with.
+const MetadataEncryptionDataKey = "encryption-data-key"
+
+// dataKeySize is the size of a generated data key, AES-256
+const dataKeySize = 32
+
+// KeyWrapper is implemented by key providers that wrap and unwrap data keys themselves, like a KMS does,
+// so the master key never has to leave the provider.
+//
+// Providers without it have the master key fetched with GetKey and the data key wrapped locally.
+type KeyWrapper interface {
+	WrapKey(keyID string, dataKey []byte) ([]byte, error)
+	UnwrapKey(keyID string, wrappedKey []byte) ([]byte, error)
+}
+
+// keyWrapperDecorator is implemented by providers that wrap another provider, like CachingKeyProvider.
+// They have the KeyWrapper methods whatever they wrap, wrapsKeys reports whether the wrapped provider is a KeyWrapper.
+type keyWrapperDecorator interface {
+	wrapsKeys() bool
+}
+
+// keyWrapper returns the KeyWrapper of provider, if it wraps keys itself
+func keyWrapper(provider KeyProvider) (KeyWrapper, bool) {
+	w, ok := provider.(KeyWrapper)
+	if d, isDecorator := provider.(keyWrapperDecorator); ok && isDecorator && !d.wrapsKeys() {
+		return nil, false
+	}
+
+	return w, ok
+}
+
+// newDataKey generates a random data key for a batch of payloads and wraps it with the master key keyID.
+// Data keys wrapped locally use the Codec's Cipher.
+func (e *Codec) newDataKey(keyID string) (dataKey []byte, wrappedKey []byte, err error) {
+	dataKey = make([]byte, dataKeySize)
+	if _, err = io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, nil, err
+	}
+
+	if w, ok := keyWrapper(e.KeyProvider); ok {
+		wrappedKey, err = w.WrapKey(keyID, dataKey)
+	} else {
+		var key []byte
+		key, err = e.getKey(keyID)
+		if err == nil {
+			wrappedKey, err = e.masterKeySeal(e.cipher(), keyID, key, dataKey)
+		}
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to wrap data key with %q: %w", keyID, err)
+	}
+
+	return dataKey, wrappedKey, nil
+}
+
+// unwrapDataKey recovers a data key wrapped by newDataKey with the Cipher of the payload
+func (e *Codec) unwrapDataKey(c Cipher, keyID string, wrappedKey []byte) ([]byte, error) {
+	var dataKey []byte
+	var err error
+	if w, ok := keyWrapper(e.KeyProvider); ok {
+		dataKey, err = w.UnwrapKey(keyID, wrappedKey)
+	} else {
+		var key []byte
+		key, err = e.getKey(keyID)
+		if err == nil {
+			dataKey, err = e.masterKeyOpen(c, keyID, key, wrappedKey)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key with %q: %w", keyID, err)
+	}
+
+	return dataKey, nil
+}
+
+// masterKeySeal wraps a data key locally with the cached AEAD of the master key
+func (e *Codec) masterKeySeal(c Cipher, keyID string, key []byte, dataKey []byte) ([]byte, error) {
+	aead, err := e.aeadCache().get(c, keyID, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return seal(aead, dataKey, nil)
+}
+
+// masterKeyOpen unwraps a data key wrapped by masterKeySeal
+func (e *Codec) masterKeyOpen(c Cipher, keyID string, key []byte, wrappedKey []byte) ([]byte, error) {
+	aead, err := e.aeadCache().get(c, keyID, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return open(aead, wrappedKey, nil)
+}
@@ -0,0 +1,46 @@
+package codec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/converter"
+)
+
+func Test_EnvelopeEncryption(t *testing.T) {
+	kms := newTestKMS(t, "s3cr3t", map[string][]byte{"key-1": testKey1})
+	provider := NewHTTPKeyProvider(kms.URL)
+	provider.Token = "s3cr3t"
+
+	dc := NewEncryptionDataConverter(converter.GetDefaultDataConverter(), DataConverterOptions{
+		KeyID:              "key-1",
+		KeyProvider:        provider,
+		EnvelopeEncryption: true,
+	})
+
+	payloads, err := dc.ToPayloads("a", "b", "c")
+	require.NoError(t, err)
+	require.Equal(t, 1, kms.callCount("/keys/key-1/wrap"), "one data key per batch")
+	require.Zero(t, kms.callCount("/keys/key-1"), "the master key never leaves the KMS")
+
+	wrappedKey := payloads.Payloads[0].GetMetadata()[MetadataEncryptionDataKey]
+	require.NotEmpty(t, wrappedKey)
+	for _, p := range payloads.Payloads {
+		require.Equal(t, "key-1", string(p.GetMetadata()[MetadataEncryptionKeyID]))
+		require.Equal(t, wrappedKey, p.GetMetadata()[MetadataEncryptionDataKey])
+	}
+
+	var a, b, c string
+	require.NoError(t, dc.FromPayloads(payloads, &a, &b, &c))
+	require.Equal(t, []string{"a", "b", "c"}, []string{a, b, c})
+	require.Equal(t, 1, kms.callCount("/keys/key-1/unwrap"), "one unwrap per batch")
+
+	// providers without a KeyWrapper wrap locally with the master key
+	local := NewEncryptionDataConverter(converter.GetDefaultDataConverter(), DataConverterOptions{EnvelopeEncryption: true})
+	p, err := local.ToPayload("local")
+	require.NoError(t, err)
+	require.NotEmpty(t, p.GetMetadata()[MetadataEncryptionDataKey])
+	var result string
+	require.NoError(t, local.FromPayload(p, &result))
+	require.Equal(t, "local", result)
+}
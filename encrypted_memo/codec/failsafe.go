@@ -0,0 +1,65 @@
+package codec
+
+import (
+	"errors"
+	"failsafe"
+
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+)
+
+// MetadataEncryptionDecodeError marks the placeholder FailsafeCodec returns for a payload that failed to decode,
+// the value is the error class.
+const MetadataEncryptionDecodeError = "encryption-decode-error"
+
+// Error classes of an UndecodablePayload
+const (
+	ErrorClassKeyNotFound      = "key-not-found"
+	ErrorClassTampered         = "tampered"
+	ErrorClassDecryptionFailed = "decryption-failed"
+	ErrorClassDecodeFailed     = "decode-failed"
+)
+
+// UndecodablePayload is the json body of the placeholder FailsafeCodec returns for a payload that failed to decode
+type UndecodablePayload struct {
+	Undecodable string `json:"undecodable"`
+	KeyID       string `json:"keyId,omitempty"`
+	Error       string `json:"error"`
+}
+
+// FailsafeCodec decodes payloads one by one through a chain of codecs, swapping the ones that fail for an
+// UndecodablePayload placeholder. It's meant for codec servers, where one bad payload would otherwise fail the
+// whole request and hide the rest of the history.
+//
+// The codecs are in the order converter.NewPayloadCodecHTTPHandler takes them, Encode fails as a whole.
+type FailsafeCodec struct {
+	*failsafe.Codec
+}
+
+var _ converter.PayloadCodec = (*FailsafeCodec)(nil) // ensure interface is implemented
+
+// NewFailsafeCodec returns a FailsafeCodec for the chain of codecs
+func NewFailsafeCodec(codecs ...converter.PayloadCodec) *FailsafeCodec {
+	return &FailsafeCodec{failsafe.New(undecodablePayload, codecs...)}
+}
+
+// undecodablePayload classifies why p couldn't be decrypted, along with the key ID it was encrypted with
+func undecodablePayload(p *commonpb.Payload, err error) *commonpb.Payload {
+	class := ErrorClassDecodeFailed
+	switch {
+	case errors.Is(err, ErrKeyNotFound):
+		class = ErrorClassKeyNotFound
+	case errors.Is(err, ErrTampered):
+		class = ErrorClassTampered
+	case errors.Is(err, ErrDecryptionFailed):
+		class = ErrorClassDecryptionFailed
+	}
+
+	payload, _ := failsafe.Placeholder(UndecodablePayload{ // can't fail for strings
+		Undecodable: class,
+		KeyID:       string(p.GetMetadata()[MetadataEncryptionKeyID]),
+		Error:       err.Error(),
+	}, MetadataEncryptionDecodeError, class)
+
+	return payload
+}
@@ -0,0 +1,87 @@
+package codec
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	commonpb "go.temporal.io/api/common/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"go.temporal.io/sdk/converter"
+)
+
+func Test_FailsafeCodec(t *testing.T) {
+	keyring, err := NewKeyring("key-1", map[string][]byte{"key-1": testKey1})
+	require.NoError(t, err)
+	dc := NewEncryptionDataConverter(converter.GetDefaultDataConverter(), DataConverterOptions{KeyProvider: keyring})
+
+	good, err := dc.ToPayload("good")
+	require.NoError(t, err)
+
+	unknownKey, err := NewEncryptionDataConverter(converter.GetDefaultDataConverter(), DataConverterOptions{
+		KeyID: "key-2",
+	}).ToPayload("unknown key")
+	require.NoError(t, err)
+
+	corrupt, err := dc.ToPayload("corrupt")
+	require.NoError(t, err)
+	corrupt.Data[len(corrupt.Data)-1] ^= 0xff
+
+	bound, err := NewEncryptionDataConverter(converter.GetDefaultDataConverter(), DataConverterOptions{
+		KeyProvider:    keyring,
+		BindToWorkflow: true,
+	}).WithSerializationContext(converter.WorkflowSerializationContext{Namespace: "default", WorkflowID: "wf-1"}).ToPayload("bound")
+	require.NoError(t, err)
+	bound.Metadata[MetadataEncryptionBinding] = []byte(`{"namespace":"default","workflowId":"wf-2","role":"workflow"}`)
+
+	var failed int
+	failsafe := NewFailsafeCodec(&Codec{KeyProvider: keyring})
+	failsafe.OnError = func(*commonpb.Payload, error) { failed++ }
+	handler := converter.NewPayloadCodecHTTPHandler(failsafe)
+
+	body, err := protojson.Marshal(&commonpb.Payloads{Payloads: []*commonpb.Payload{good, unknownKey, corrupt, bound}})
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/decode", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var decoded commonpb.Payloads
+	require.NoError(t, protojson.Unmarshal(rec.Body.Bytes(), &decoded))
+	require.Len(t, decoded.Payloads, 4)
+	require.Equal(t, 3, failed)
+
+	var s string
+	require.NoError(t, converter.GetDefaultDataConverter().FromPayload(decoded.Payloads[0], &s))
+	require.Equal(t, "good", s)
+
+	for i, want := range []UndecodablePayload{
+		{Undecodable: ErrorClassKeyNotFound, KeyID: "key-2"},
+		{Undecodable: ErrorClassDecryptionFailed, KeyID: "key-1"},
+		// without a serialization context the stored binding is used, which was changed
+		{Undecodable: ErrorClassDecryptionFailed, KeyID: "key-1"},
+	} {
+		p := decoded.Payloads[i+1]
+		require.Equal(t, want.Undecodable, string(p.GetMetadata()[MetadataEncryptionDecodeError]))
+
+		var placeholder UndecodablePayload
+		require.NoError(t, converter.GetDefaultDataConverter().FromPayload(p, &placeholder))
+		require.Equal(t, want.Undecodable, placeholder.Undecodable)
+		require.Equal(t, want.KeyID, placeholder.KeyID)
+		require.NotEmpty(t, placeholder.Error)
+	}
+
+	// tampering is reported when the payload is decoded in a serialization context
+	tampered := undecodablePayload(bound, &TamperError{Err: ErrDecryptionFailed})
+	require.Equal(t, ErrorClassTampered, string(tampered.GetMetadata()[MetadataEncryptionDecodeError]))
+
+	// encoding goes through the chain as a whole
+	encoded, err := failsafe.Encode([]*commonpb.Payload{decoded.Payloads[0]})
+	require.NoError(t, err)
+	require.NoError(t, dc.FromPayload(encoded[0], &s))
+	require.Equal(t, "good", s)
+}
@@ -0,0 +1,23 @@
+package codec
+
+import (
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/temporal"
+)
+
+// DefaultFailureConverter encrypts failure messages and stack traces with DefaultEncryptionCodec
+var DefaultFailureConverter = NewFailureConverter(DefaultEncryptionCodec)
+
+// NewFailureConverter returns a FailureConverter that moves the message and stack trace of each failure into
+// its encoded attributes, a payload encoded by dataConverter. Error messages and stack traces often contain
+// customer data, with an encryption DataConverter they get the same protection as the payloads.
+// The history then shows "Encoded failure" as the message.
+//
+// Clients and workers restore the message and stack trace when they use the FailureConverter too,
+// the codec server decodes the encoded attributes for the UI like any other payload.
+func NewFailureConverter(dataConverter converter.DataConverter) converter.FailureConverter {
+	return temporal.NewDefaultFailureConverter(temporal.DefaultFailureConverterOptions{
+		DataConverter:          dataConverter,
+		EncodeCommonAttributes: true,
+	})
+}
@@ -0,0 +1,75 @@
+package codec
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/api/enums/v1"
+	failurepb "go.temporal.io/api/failure/v1"
+	historypb "go.temporal.io/api/history/v1"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/mocks"
+	"go.temporal.io/sdk/temporal"
+)
+
+func Test_FailureConverter(t *testing.T) {
+	fc := NewFailureConverter(NewEncryptionDataConverter(converter.GetDefaultDataConverter(), DataConverterOptions{}))
+
+	failure := fc.ErrorToFailure(temporal.NewApplicationError("card 4111-1111-1111-1111 declined", "PaymentError"))
+	require.Equal(t, "Encoded failure", failure.GetMessage())
+	require.Empty(t, failure.GetStackTrace())
+	require.Equal(t, MetadataEncodingEncrypted, string(failure.GetEncodedAttributes().GetMetadata()[converter.MetadataEncoding]))
+	require.NotContains(t, string(failure.GetEncodedAttributes().GetData()), "4111")
+
+	// the client restores the message
+	var appErr *temporal.ApplicationError
+	require.True(t, errors.As(fc.FailureToError(failure), &appErr))
+	require.Equal(t, "card 4111-1111-1111-1111 declined", appErr.Message())
+	require.Equal(t, "PaymentError", appErr.Type())
+
+	// and so does the codec chain of the codec server
+	decoded, err := NewFailsafeCodec(&Codec{}).Decode([]*commonpb.Payload{failure.GetEncodedAttributes()})
+	require.NoError(t, err)
+	require.Contains(t, string(decoded[0].GetData()), "card 4111-1111-1111-1111 declined")
+}
+
+func Test_DecodingClientFailures(t *testing.T) {
+	fc := NewFailureConverter(NewEncryptionDataConverter(converter.GetDefaultDataConverter(), DataConverterOptions{}))
+	failure := func() *failurepb.Failure {
+		return fc.ErrorToFailure(temporal.NewApplicationError("customer jane declined", "PaymentError"))
+	}
+	event := func(f *failurepb.Failure) *historypb.HistoryEvent {
+		return &historypb.HistoryEvent{
+			EventId: 1,
+			Attributes: &historypb.HistoryEvent_WorkflowExecutionFailedEventAttributes{
+				WorkflowExecutionFailedEventAttributes: &historypb.WorkflowExecutionFailedEventAttributes{Failure: f},
+			},
+		}
+	}
+	ctx := context.Background()
+
+	keyring, err := NewKeyring("other", map[string][]byte{"other": testKey2})
+	require.NoError(t, err)
+	for name, tt := range map[string]struct {
+		codec   converter.PayloadCodec
+		message string
+	}{
+		"decoded":     {codec: &Codec{}, message: "customer jane declined"},
+		"undecodable": {codec: NewFailsafeCodec(&Codec{KeyProvider: keyring}), message: "Encoded failure"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			iterator := &mocks.HistoryEventIterator{}
+			iterator.On("Next").Return(event(failure()), nil)
+			mockClient := &mocks.Client{}
+			mockClient.On("GetWorkflowHistory", ctx, "wf", "run", false, enums.HISTORY_EVENT_FILTER_TYPE_ALL_EVENT).Return(iterator)
+
+			e, err := NewDecodingClient(mockClient, tt.codec).
+				GetWorkflowHistory(ctx, "wf", "run", false, enums.HISTORY_EVENT_FILTER_TYPE_ALL_EVENT).Next()
+			require.NoError(t, err)
+			require.Equal(t, tt.message, e.GetWorkflowExecutionFailedEventAttributes().GetFailure().GetMessage())
+		})
+	}
+}
@@ -0,0 +1,351 @@
+package codec
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	commonpb "go.temporal.io/api/common/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"go.temporal.io/sdk/converter"
+)
+
+const (
+	// MetadataEncodingFieldEncrypted is "json/field-encrypted", json with some fields encrypted.
+	// Each encrypted field's value is the json encoded Payload produced by Codec.
+	MetadataEncodingFieldEncrypted = "json/field-encrypted"
+
+	// MetadataEncryptedFields is "encrypted-fields", the json array of the field names that are encrypted
+	MetadataEncryptedFields = "encrypted-fields"
+
+	// StructTagKey is the struct tag marking fields to encrypt: `temporal:"encrypt"`
+	StructTagKey     = "temporal"
+	structTagEncrypt = "encrypt"
+)
+
+// FieldEncryptionPayloadConverter encrypts the struct fields tagged `temporal:"encrypt"` and leaves the rest
+// as readable json. Values without tagged fields are left to the next PayloadConverter.
+//
+// The top-level fields of a struct, or pointer to a struct, are encrypted, including those promoted from
+// embedded structs. A tagged field holding a struct is encrypted as a whole. Tagged fields nested deeper,
+// e.g. in a struct field that isn't tagged itself or in the elements of a slice or map value, fail ToPayload
+// rather than being left in plaintext.
+//
+// The Codec is fixed, it isn't switched to the key of a propagated tenant like DataConverter.WithContext does.
+type FieldEncryptionPayloadConverter struct {
+	codec *Codec
+}
+
+var _ converter.PayloadConverter = (*FieldEncryptionPayloadConverter)(nil) // ensure interface is implemented
+
+// NewFieldEncryptionPayloadConverter returns a FieldEncryptionPayloadConverter encrypting fields with codec
+func NewFieldEncryptionPayloadConverter(codec *Codec) *FieldEncryptionPayloadConverter {
+	return &FieldEncryptionPayloadConverter{codec: codec}
+}
+
+// NewFieldEncryptionDataConverter is the default DataConverter with a FieldEncryptionPayloadConverter.
+// It can be wrapped by NewEncryptionDataConverter to also encrypt whole payloads.
+//
+// It implements converter.DataConverterWithSerializationContext, so fields are bound to the workflow
+// with Codec.BindToWorkflow.
+func NewFieldEncryptionDataConverter(codec *Codec) converter.DataConverter {
+	return &fieldEncryptionDataConverter{
+		DataConverter: converter.NewCompositeDataConverter(
+			converter.NewNilPayloadConverter(),
+			converter.NewByteSlicePayloadConverter(),
+			// must come before the json converters, it only handles structs with tagged fields
+			NewFieldEncryptionPayloadConverter(codec),
+			converter.NewProtoJSONPayloadConverter(),
+			converter.NewProtoPayloadConverter(),
+			converter.NewJSONPayloadConverter(),
+		),
+		codec: codec,
+	}
+}
+
+// fieldEncryptionDataConverter passes the serialization context on to the Codec of its fields,
+// a CompositeDataConverter doesn't pass it to its PayloadConverters
+type fieldEncryptionDataConverter struct {
+	converter.DataConverter
+	codec *Codec
+}
+
+var _ converter.DataConverterWithSerializationContext = (*fieldEncryptionDataConverter)(nil) // ensure interface is implemented
+
+// WithSerializationContext implements converter.DataConverterWithSerializationContext.
+func (dc *fieldEncryptionDataConverter) WithSerializationContext(ctx converter.SerializationContext) converter.DataConverter {
+	return NewFieldEncryptionDataConverter(dc.codec.WithSerializationContext(ctx).(*Codec))
+}
+
+// ToPayload converts a struct with tagged fields to json, encrypting the tagged fields.
+func (c *FieldEncryptionPayloadConverter) ToPayload(value interface{}) (*commonpb.Payload, error) {
+	fields, err := encryptedFields(reflect.TypeOf(value))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", converter.ErrUnableToEncode, err)
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", converter.ErrUnableToEncode, err)
+	}
+
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, fmt.Errorf("%w: %v", converter.ErrUnableToEncode, err)
+	}
+
+	// omitempty fields may be missing, the ones present are encrypted as one batch
+	var names []string
+	var plain []*commonpb.Payload
+	for _, name := range fields {
+		if raw, ok := obj[name]; ok {
+			names = append(names, name)
+			plain = append(plain, &commonpb.Payload{
+				Metadata: map[string][]byte{converter.MetadataEncoding: []byte(converter.MetadataEncodingJSON)},
+				Data:     raw,
+			})
+		}
+	}
+
+	encrypted, err := c.codec.Encode(plain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt fields: %w", err)
+	}
+	for i, name := range names {
+		if obj[name], err = protojson.Marshal(encrypted[i]); err != nil {
+			return nil, fmt.Errorf("%w: %v", converter.ErrUnableToEncode, err)
+		}
+	}
+
+	if data, err = json.Marshal(obj); err != nil {
+		return nil, fmt.Errorf("%w: %v", converter.ErrUnableToEncode, err)
+	}
+	encryptedNames, _ := json.Marshal(names) // can't fail for a slice of strings
+
+	return &commonpb.Payload{
+		Metadata: map[string][]byte{
+			converter.MetadataEncoding: []byte(MetadataEncodingFieldEncrypted),
+			MetadataEncryptedFields:    encryptedNames,
+		},
+		Data: data,
+	}, nil
+}
+
+// FromPayload decrypts the encrypted fields and converts the json to valuePtr.
+func (c *FieldEncryptionPayloadConverter) FromPayload(payload *commonpb.Payload, valuePtr interface{}) error {
+	data, err := decryptFields(c.codec, payload)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(data, valuePtr); err != nil {
+		return fmt.Errorf("%w: %v", converter.ErrUnableToDecode, err)
+	}
+
+	return nil
+}
+
+// ToString returns the json with the fields still encrypted.
+func (c *FieldEncryptionPayloadConverter) ToString(payload *commonpb.Payload) string {
+	return string(payload.GetData())
+}
+
+// Encoding returns MetadataEncodingFieldEncrypted.
+func (c *FieldEncryptionPayloadConverter) Encoding() string {
+	return MetadataEncodingFieldEncrypted
+}
+
+// FieldCodec decodes field encrypted payloads to plain json payloads, for the codec server to show them in the UI.
+// Encode leaves payloads unchanged, fields are only encrypted by the FieldEncryptionPayloadConverter.
+//
+// It must be the last codec of a chain so it decodes after whole payloads are decrypted and decompressed.
+type FieldCodec struct {
+	Codec *Codec
+}
+
+var _ converter.PayloadCodec = (*FieldCodec)(nil) // ensure interface is implemented
+
+// Encode implements converter.PayloadCodec.Encode.
+func (f *FieldCodec) Encode(payloads []*commonpb.Payload) ([]*commonpb.Payload, error) {
+	return payloads, nil
+}
+
+// Decode implements converter.PayloadCodec.Decode.
+func (f *FieldCodec) Decode(payloads []*commonpb.Payload) ([]*commonpb.Payload, error) {
+	result := make([]*commonpb.Payload, len(payloads))
+	for i, p := range payloads {
+		if string(p.GetMetadata()[converter.MetadataEncoding]) != MetadataEncodingFieldEncrypted {
+			result[i] = p
+			continue
+		}
+
+		data, err := decryptFields(f.Codec, p)
+		if err != nil {
+			return payloads, err
+		}
+
+		result[i] = &commonpb.Payload{
+			Metadata: map[string][]byte{converter.MetadataEncoding: []byte(converter.MetadataEncodingJSON)},
+			Data:     data,
+		}
+	}
+
+	return result, nil
+}
+
+// EncryptedFieldPayloads returns the encrypted payloads of the fields of a json/field-encrypted payload,
+// e.g. to read their key IDs without decrypting them
+func EncryptedFieldPayloads(payload *commonpb.Payload) ([]*commonpb.Payload, error) {
+	_, _, encrypted, err := parseEncryptedFields(payload)
+	return encrypted, err
+}
+
+// parseEncryptedFields returns the names of the encrypted fields of a field encrypted payload,
+// its json object and the encrypted payloads of the fields
+func parseEncryptedFields(payload *commonpb.Payload) ([]string, map[string]json.RawMessage, []*commonpb.Payload, error) {
+	var names []string
+	if err := json.Unmarshal(payload.GetMetadata()[MetadataEncryptedFields], &names); err != nil {
+		return nil, nil, nil, fmt.Errorf("%w: invalid %s: %v", converter.ErrUnableToDecode, MetadataEncryptedFields, err)
+	}
+	if len(names) == 0 {
+		return nil, nil, nil, nil
+	}
+
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(payload.GetData(), &obj); err != nil {
+		return nil, nil, nil, fmt.Errorf("%w: %v", converter.ErrUnableToDecode, err)
+	}
+
+	encrypted := make([]*commonpb.Payload, len(names))
+	for i, name := range names {
+		encrypted[i] = &commonpb.Payload{}
+		if err := protojson.Unmarshal(obj[name], encrypted[i]); err != nil {
+			return nil, nil, nil, fmt.Errorf("%w: field %s: %v", converter.ErrUnableToDecode, name, err)
+		}
+	}
+
+	return names, obj, encrypted, nil
+}
+
+// decryptFields returns the json of a field encrypted payload with its fields decrypted
+func decryptFields(codec *Codec, payload *commonpb.Payload) ([]byte, error) {
+	names, obj, encrypted, err := parseEncryptedFields(payload)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return payload.GetData(), nil
+	}
+
+	plain, err := codec.Decode(encrypted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt fields: %w", err)
+	}
+	for i, name := range names {
+		obj[name] = plain[i].GetData()
+	}
+
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", converter.ErrUnableToDecode, err)
+	}
+
+	return data, nil
+}
+
+// encryptedFields returns the json names of the fields tagged `temporal:"encrypt"`, including those of
+// embedded structs, which json promotes to the top level. Tagged fields it can't encrypt are an error.
+func encryptedFields(t reflect.Type) ([]string, error) {
+	if t == nil {
+		return nil, nil
+	}
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		// e.g. a slice or map of structs, which would reach the json converter with the fields in plaintext
+		if hasTaggedFields(t, map[reflect.Type]bool{}) {
+			return nil, fmt.Errorf("%s holds fields tagged %q, only the fields of a struct value are encrypted",
+				t, structTagEncrypt)
+		}
+		return nil, nil
+	}
+
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tagged := f.Tag.Get(StructTagKey) == structTagEncrypt
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		// json promotes the fields of an embedded struct without a name, even of an unexported type
+		embedded := f.Type
+		if embedded.Kind() == reflect.Pointer {
+			embedded = embedded.Elem()
+		}
+		if f.Anonymous && name == "" && embedded.Kind() == reflect.Struct {
+			if tagged {
+				return nil, fmt.Errorf("embedded struct %s is tagged %q, give it a json name to encrypt it as a whole",
+					f.Name, structTagEncrypt)
+			}
+			promoted, err := encryptedFields(embedded)
+			if err != nil {
+				return nil, err
+			}
+			names = append(names, promoted...)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+		if tagged {
+			names = append(names, name)
+		} else if hasTaggedFields(f.Type, map[reflect.Type]bool{}) {
+			return nil, fmt.Errorf("field %s has nested fields tagged %q, tag %s itself to encrypt it as a whole",
+				name, structTagEncrypt, name)
+		}
+	}
+
+	return names, nil
+}
+
+// hasTaggedFields reports whether t, or the structs it holds, have fields tagged `temporal:"encrypt"`
+func hasTaggedFields(t reflect.Type, seen map[reflect.Type]bool) bool {
+	t = indirect(t)
+	if t.Kind() != reflect.Struct || seen[t] {
+		return false
+	}
+	seen[t] = true
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Tag.Get(StructTagKey) == structTagEncrypt || hasTaggedFields(f.Type, seen) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// indirect returns the element type of pointers, slices, arrays and maps
+func indirect(t reflect.Type) reflect.Type {
+	for {
+		switch t.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+			t = t.Elem()
+		default:
+			return t
+		}
+	}
+}
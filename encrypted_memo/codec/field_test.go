@@ -0,0 +1,193 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	commonpb "go.temporal.io/api/common/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+
+	"go.temporal.io/sdk/converter"
+)
+
+type testAddress struct {
+	Street string `json:"street"`
+}
+
+type testOrder struct {
+	ID      string       `json:"id"`
+	Status  string       `json:"status"`
+	Card    string       `json:"card" temporal:"encrypt"`
+	Address *testAddress `json:"address,omitempty" temporal:"encrypt"`
+	Notes   string       `temporal:"encrypt"`
+}
+
+func Test_FieldEncryption(t *testing.T) {
+	dc := NewFieldEncryptionDataConverter(&Codec{})
+
+	order := testOrder{
+		ID:      "order-1",
+		Status:  "paid",
+		Card:    "4111 1111 1111 1111",
+		Address: &testAddress{Street: "1 Secret Lane"},
+		Notes:   "leave at the door",
+	}
+	p, err := dc.ToPayload(order)
+	require.NoError(t, err)
+	require.Equal(t, MetadataEncodingFieldEncrypted, string(p.GetMetadata()[converter.MetadataEncoding]))
+	require.JSONEq(t, `["card","address","Notes"]`, string(p.GetMetadata()[MetadataEncryptedFields]))
+
+	// untagged fields stay readable
+	require.Contains(t, string(p.GetData()), `"id":"order-1"`)
+	require.Contains(t, string(p.GetData()), `"status":"paid"`)
+	require.NotContains(t, string(p.GetData()), "4111")
+	require.NotContains(t, string(p.GetData()), "Secret Lane")
+	require.NotContains(t, string(p.GetData()), "the door")
+
+	var result testOrder
+	require.NoError(t, dc.FromPayload(p, &result))
+	require.Equal(t, order, result)
+
+	// pointers and omitted fields
+	p, err = dc.ToPayload(&testOrder{ID: "order-2", Card: "5500"})
+	require.NoError(t, err)
+	require.JSONEq(t, `["card","Notes"]`, string(p.GetMetadata()[MetadataEncryptedFields]))
+	result = testOrder{}
+	require.NoError(t, dc.FromPayload(p, &result))
+	require.Equal(t, testOrder{ID: "order-2", Card: "5500"}, result)
+
+	// the key IDs of the fields can be read without the key
+	fields, err := EncryptedFieldPayloads(p)
+	require.NoError(t, err)
+	require.Len(t, fields, 2)
+	for _, f := range fields {
+		require.Equal(t, MetadataEncodingEncrypted, string(f.GetMetadata()[converter.MetadataEncoding]))
+		require.Contains(t, f.GetMetadata(), MetadataEncryptionKeyID)
+	}
+
+	// values without tagged fields use the regular converters
+	p, err = dc.ToPayload(testAddress{Street: "public"})
+	require.NoError(t, err)
+	require.Equal(t, converter.MetadataEncodingJSON, string(p.GetMetadata()[converter.MetadataEncoding]))
+}
+
+func Test_FieldEncryptionWithEncryptionDataConverter(t *testing.T) {
+	fieldCodec := &Codec{KeyID: "field-key"}
+	dc := NewEncryptionDataConverter(NewFieldEncryptionDataConverter(fieldCodec), DataConverterOptions{Compress: true})
+
+	order := testOrder{ID: "order-1", Card: "4111"}
+	p, err := dc.ToPayload(order)
+	require.NoError(t, err)
+	require.Equal(t, MetadataEncodingEncrypted, string(p.GetMetadata()[converter.MetadataEncoding]))
+
+	var result testOrder
+	require.NoError(t, dc.FromPayload(p, &result))
+	require.Equal(t, order, result)
+}
+
+func Test_FieldCodecServer(t *testing.T) {
+	fieldCodec := &Codec{KeyID: "field-key"}
+	dc := NewFieldEncryptionDataConverter(fieldCodec)
+
+	order := testOrder{ID: "order-1", Card: "4111"}
+	p, err := dc.ToPayload(order)
+	require.NoError(t, err)
+
+	handler := converter.NewPayloadCodecHTTPHandler(
+		&Codec{},
+		converter.NewZlibCodec(converter.ZlibCodecOptions{AlwaysEncode: true}),
+		&FieldCodec{Codec: fieldCodec},
+	)
+
+	body, err := protojson.Marshal(&commonpb.Payloads{Payloads: []*commonpb.Payload{p}})
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/decode", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var decoded commonpb.Payloads
+	require.NoError(t, protojson.Unmarshal(rec.Body.Bytes(), &decoded))
+	require.Len(t, decoded.Payloads, 1)
+	require.Equal(t, converter.MetadataEncodingJSON, string(decoded.Payloads[0].GetMetadata()[converter.MetadataEncoding]))
+
+	var result testOrder
+	require.NoError(t, json.Unmarshal(decoded.Payloads[0].GetData(), &result))
+	require.Equal(t, order, result)
+}
+
+type testCustomer struct {
+	Email string `json:"email" temporal:"encrypt"`
+}
+
+type testEmbeddedOrder struct {
+	testCustomer
+	testShipping
+	ID string `json:"id"`
+}
+
+type testShipping struct {
+	Street string `json:"street" temporal:"encrypt"`
+}
+
+func Test_FieldEncryptionEmbedded(t *testing.T) {
+	dc := NewFieldEncryptionDataConverter(&Codec{})
+
+	order := testEmbeddedOrder{
+		testCustomer: testCustomer{Email: "jane@example.com"},
+		testShipping: testShipping{Street: "1 Secret Lane"},
+		ID:           "order-1",
+	}
+	p, err := dc.ToPayload(order)
+	require.NoError(t, err)
+	require.JSONEq(t, `["email","street"]`, string(p.GetMetadata()[MetadataEncryptedFields]))
+	require.Contains(t, string(p.GetData()), `"id":"order-1"`)
+	require.NotContains(t, string(p.GetData()), "jane@example.com")
+	require.NotContains(t, string(p.GetData()), "Secret Lane")
+
+	var result testEmbeddedOrder
+	require.NoError(t, dc.FromPayload(p, &result))
+	require.Equal(t, order, result)
+
+	// tagged fields nested in an untagged struct field would be left in plaintext
+	_, err = dc.ToPayload(struct {
+		Customer testCustomer `json:"customer"`
+	}{Customer: testCustomer{Email: "jane@example.com"}})
+	require.ErrorIs(t, err, converter.ErrUnableToEncode)
+	require.ErrorContains(t, err, "customer")
+
+	// so would the tagged fields of the elements of a slice or map
+	customers := []testCustomer{{Email: "jane@example.com"}}
+	for name, value := range map[string]interface{}{
+		"slice":            customers,
+		"map":              map[string]testCustomer{"jane": {Email: "jane@example.com"}},
+		"pointer to slice": &customers,
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := dc.ToPayload(value)
+			require.ErrorIs(t, err, converter.ErrUnableToEncode)
+		})
+	}
+}
+
+func Test_FieldEncryptionSerializationContext(t *testing.T) {
+	dc := NewFieldEncryptionDataConverter(&Codec{BindToWorkflow: true})
+
+	wf1 := dc.(converter.DataConverterWithSerializationContext).WithSerializationContext(
+		converter.WorkflowSerializationContext{Namespace: "default", WorkflowID: "wf-1"})
+	p, err := wf1.ToPayload(testOrder{ID: "order-1", Card: "4111"})
+	require.NoError(t, err)
+
+	var result testOrder
+	require.NoError(t, wf1.FromPayload(p, &result))
+	require.Equal(t, "4111", result.Card)
+
+	wf2 := dc.(converter.DataConverterWithSerializationContext).WithSerializationContext(
+		converter.WorkflowSerializationContext{Namespace: "default", WorkflowID: "wf-2"})
+	require.ErrorIs(t, wf2.FromPayload(p, &result), ErrTampered)
+}
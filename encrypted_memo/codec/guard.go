@@ -0,0 +1,162 @@
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+var (
+	// ErrPlaintextMemo is the panic of a workflow that upserted a memo the DataConverter didn't encrypt
+	ErrPlaintextMemo = errors.New("memo is not encrypted")
+
+	// ErrPIISearchAttribute is the panic of a workflow that upserted a search attribute matching a PII pattern
+	ErrPIISearchAttribute = errors.New("search attribute value matches a PII pattern")
+)
+
+// DefaultPIIPatterns are the search attribute values GuardInterceptor blocks when GuardOptions has no patterns:
+// email addresses, US social security numbers and payment card numbers.
+var DefaultPIIPatterns = map[string]*regexp.Regexp{
+	"email":       regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	"ssn":         regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`),
+	"card-number": regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+}
+
+// GuardOptions configure a GuardInterceptor
+type GuardOptions struct {
+	// AllowPlaintextMemo turns off the memo check, e.g. while migrating a namespace.
+	AllowPlaintextMemo bool
+	// PIIPatterns by name, string and keyword list search attribute values must not match any of them.
+	// Defaults to DefaultPIIPatterns.
+	PIIPatterns map[string]*regexp.Regexp
+}
+
+// GuardInterceptor makes sure a workflow doesn't leak data into the visibility store.
+//
+// Memos are only encrypted by the DataConverter when TEMPORAL_SDK_FLAG_7=1 is set, otherwise they're silently
+// written in plaintext. The interceptor checks each upserted memo is encoded as binary/encrypted, and blocks
+// search attribute upserts with values matching a PII pattern. Search attributes are never encrypted,
+// use a SearchTokenizer for values that need to be searchable.
+//
+// A violation panics, which fails the workflow task so the memo or search attributes are never recorded,
+// and the workflow retries the task once it's fixed. Memos and search attributes set when starting a workflow
+// or a child workflow aren't checked.
+type GuardInterceptor struct {
+	interceptor.WorkerInterceptorBase
+	options GuardOptions
+}
+
+var _ interceptor.WorkerInterceptor = (*GuardInterceptor)(nil) // ensure interface is implemented
+
+// NewGuardInterceptor returns a worker interceptor that fails workflow tasks upserting plaintext memos
+// or PII search attributes
+func NewGuardInterceptor(options GuardOptions) *GuardInterceptor {
+	if options.PIIPatterns == nil {
+		options.PIIPatterns = DefaultPIIPatterns
+	}
+
+	return &GuardInterceptor{options: options}
+}
+
+// InterceptWorkflow
+//
+//	Temporal Server > * > 1st line of the Workflow
+func (g *GuardInterceptor) InterceptWorkflow(ctx workflow.Context, next interceptor.WorkflowInboundInterceptor) interceptor.WorkflowInboundInterceptor {
+	return &guardWfInbound{
+		WorkflowInboundInterceptorBase: interceptor.WorkflowInboundInterceptorBase{Next: next},
+		options:                        g.options,
+	}
+}
+
+type guardWfInbound struct {
+	interceptor.WorkflowInboundInterceptorBase
+	options GuardOptions
+}
+
+func (i *guardWfInbound) Init(outbound interceptor.WorkflowOutboundInterceptor) error {
+	return i.Next.Init(&guardWfOutbound{
+		WorkflowOutboundInterceptorBase: interceptor.WorkflowOutboundInterceptorBase{Next: outbound},
+		options:                         i.options,
+	})
+}
+
+type guardWfOutbound struct {
+	interceptor.WorkflowOutboundInterceptorBase
+	options GuardOptions
+}
+
+// UpsertMemo checks the memo after it's encoded, the encoding is only known once the SDK picked the DataConverter
+func (o *guardWfOutbound) UpsertMemo(ctx workflow.Context, memo map[string]interface{}) error {
+	if err := o.Next.UpsertMemo(ctx, memo); err != nil || o.options.AllowPlaintextMemo {
+		return err
+	}
+
+	fields := workflow.GetInfo(ctx).Memo.GetFields()
+	for _, key := range sortedKeys(memo) {
+		p, ok := fields[key]
+		if !ok {
+			continue // deleted
+		}
+		if encoding := string(p.GetMetadata()[converter.MetadataEncoding]); encoding != MetadataEncodingEncrypted {
+			panic(fmt.Errorf("%w: %q is encoded as %s, is TEMPORAL_SDK_FLAG_7=1 set on the worker?", ErrPlaintextMemo, key, encoding))
+		}
+	}
+
+	return nil
+}
+
+func (o *guardWfOutbound) UpsertSearchAttributes(ctx workflow.Context, attributes map[string]interface{}) error {
+	for _, key := range sortedKeys(attributes) {
+		o.checkSearchAttribute(key, attributes[key])
+	}
+
+	return o.Next.UpsertSearchAttributes(ctx, attributes)
+}
+
+func (o *guardWfOutbound) UpsertTypedSearchAttributes(ctx workflow.Context, attributes ...temporal.SearchAttributeUpdate) error {
+	values := map[string]interface{}{}
+	for key, value := range temporal.NewSearchAttributes(attributes...).GetUntypedValues() {
+		values[key.GetName()] = value
+	}
+	for _, key := range sortedKeys(values) {
+		o.checkSearchAttribute(key, values[key])
+	}
+
+	return o.Next.UpsertTypedSearchAttributes(ctx, attributes...)
+}
+
+// checkSearchAttribute panics when a string or keyword list value matches a PII pattern.
+// The value itself isn't in the panic, it would end up in the workflow task failure.
+func (o *guardWfOutbound) checkSearchAttribute(key string, value interface{}) {
+	var values []string
+	switch v := value.(type) {
+	case string:
+		values = []string{v}
+	case []string:
+		values = v
+	}
+
+	for _, name := range sortedKeys(o.options.PIIPatterns) {
+		for _, v := range values {
+			if o.options.PIIPatterns[name].MatchString(v) {
+				panic(fmt.Errorf("%w: %q matches %s", ErrPIISearchAttribute, key, name))
+			}
+		}
+	}
+}
+
+// sortedKeys keeps the checks deterministic, so a workflow fails with the same error on every attempt
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
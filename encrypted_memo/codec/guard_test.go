@@ -0,0 +1,106 @@
+package codec
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/interceptor"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
+)
+
+func runGuarded(t *testing.T, options GuardOptions, wf func(ctx workflow.Context) error) error {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+	env.SetDataConverter(NewEncryptionDataConverter(converter.GetDefaultDataConverter(), DataConverterOptions{}))
+	env.SetWorkerOptions(worker.Options{
+		Interceptors: []interceptor.WorkerInterceptor{NewGuardInterceptor(options)},
+	})
+	env.RegisterWorkflowWithOptions(wf, workflow.RegisterOptions{Name: t.Name()})
+
+	env.ExecuteWorkflow(t.Name())
+	require.True(t, env.IsWorkflowCompleted())
+	return env.GetWorkflowError()
+}
+
+func Test_GuardPlaintextMemo(t *testing.T) {
+	// without TEMPORAL_SDK_FLAG_7 the memo is encoded by the default DataConverter
+	err := runGuarded(t, GuardOptions{}, func(ctx workflow.Context) error {
+		return workflow.UpsertMemo(ctx, map[string]interface{}{"secret": "plaintext"})
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), ErrPlaintextMemo.Error())
+	require.Contains(t, err.Error(), `"secret" is encoded as json/plain`)
+
+	err = runGuarded(t, GuardOptions{AllowPlaintextMemo: true}, func(ctx workflow.Context) error {
+		return workflow.UpsertMemo(ctx, map[string]interface{}{"secret": "plaintext"})
+	})
+	require.NoError(t, err)
+}
+
+func Test_GuardEncryptedMemo(t *testing.T) {
+	p, err := NewEncryptionDataConverter(converter.GetDefaultDataConverter(), DataConverterOptions{}).ToPayload("secret")
+	require.NoError(t, err)
+
+	err = runGuarded(t, GuardOptions{}, func(ctx workflow.Context) error {
+		if err := workflow.UpsertMemo(ctx, map[string]interface{}{"secret": converter.NewRawValue(p)}); err != nil {
+			return err
+		}
+		// deleting a key is fine
+		return workflow.UpsertMemo(ctx, map[string]interface{}{"secret": nil})
+	})
+	require.NoError(t, err)
+}
+
+func Test_GuardSearchAttributes(t *testing.T) {
+	tests := []struct {
+		name    string
+		options GuardOptions
+		value   interface{}
+		match   string
+	}{
+		{name: "email", value: "jane@example.com", match: "email"},
+		{name: "ssn", value: "my ssn is 123-45-6789", match: "ssn"},
+		{name: "card-number", value: []string{"ok", "4111 1111 1111 1111"}, match: "card-number"},
+		{name: "clean", value: "order-42"},
+		{name: "token", value: "5d41402abc4b2a76b9719d911017c592"},
+		{name: "custom", options: GuardOptions{PIIPatterns: map[string]*regexp.Regexp{"customer": regexp.MustCompile(`^cust-`)}},
+			value: "cust-1", match: "customer"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := runGuarded(t, tt.options, func(ctx workflow.Context) error {
+				return workflow.UpsertSearchAttributes(ctx, map[string]interface{}{"CustomKeywordField": tt.value})
+			})
+			if tt.match == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			require.Contains(t, err.Error(), ErrPIISearchAttribute.Error())
+			require.Contains(t, err.Error(), `"CustomKeywordField" matches `+tt.match)
+		})
+	}
+}
+
+func Test_GuardTypedSearchAttributes(t *testing.T) {
+	key := temporal.NewSearchAttributeKeyKeyword("CustomKeywordField")
+
+	err := runGuarded(t, GuardOptions{}, func(ctx workflow.Context) error {
+		return workflow.UpsertTypedSearchAttributes(ctx, key.ValueSet("jane@example.com"))
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `"CustomKeywordField" matches email`)
+	// the value isn't leaked through the error
+	require.NotContains(t, err.Error(), "jane@example.com")
+
+	err = runGuarded(t, GuardOptions{}, func(ctx workflow.Context) error {
+		return workflow.UpsertTypedSearchAttributes(ctx, key.ValueSet("order-42"))
+	})
+	require.NoError(t, err)
+}
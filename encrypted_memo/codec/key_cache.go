@@ -0,0 +1,297 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// CachingKeyProviderOptions configure a CachingKeyProvider
+type CachingKeyProviderOptions struct {
+	// TTL of a fetched key, defaults to 5 minutes.
+	TTL time.Duration
+	// RefreshAfter is the age after which a key is refreshed in the background while the cached one is
+	// still returned, defaults to half the TTL. Keys in use are then never fetched on a workflow task.
+	RefreshAfter time.Duration
+	// NegativeTTL of ErrKeyNotFound and ErrKeyShredded, defaults to 30 seconds.
+	NegativeTTL time.Duration
+	// MaxStale is how long past its TTL a key is still returned when the provider fails,
+	// e.g. while the KMS is down. Defaults to 15 minutes, a negative value disables it.
+	MaxStale time.Duration
+}
+
+// CachingKeyProvider caches the keys of a remote KeyProvider like HTTPKeyProvider, so workflow tasks don't wait
+// on the KMS and workers keep running through a brief KMS outage. Concurrent lookups of a key share one request.
+//
+// When the provider is a KeyWrapper, data keys are cached by their wrapped bytes with the same TTL and MaxStale,
+// so decoding a history unwraps each data key once and keeps working through an outage. WrapKey still calls the
+// provider, envelope encryption with a KMS that wraps data keys itself needs it once per encoded batch.
+type CachingKeyProvider struct {
+	provider KeyProvider
+	options  CachingKeyProviderOptions
+	now      func() time.Time // overridden by tests
+
+	mu        sync.Mutex
+	entries   map[string]*cachedKey
+	dataKeys  map[dataKeyID]*cachedKey
+	lastEvict time.Time // of the expired dataKeys
+	group     singleflight.Group
+
+	aeads aeadCache
+}
+
+// cachedKey is a fetched key, or the ErrKeyNotFound or ErrKeyShredded returned for it
+type cachedKey struct {
+	key       []byte
+	err       error
+	fetchedAt time.Time
+
+	refreshing bool // guarded by CachingKeyProvider.mu
+}
+
+// dataKeyID is an unwrapped data key, by the key ID it's wrapped with and its wrapped bytes
+type dataKeyID struct {
+	keyID      string
+	wrappedKey string
+}
+
+var _ ActiveKeyProvider = (*CachingKeyProvider)(nil) // ensure interface is implemented
+var _ KeyShredder = (*CachingKeyProvider)(nil)       // ensure interface is implemented
+var _ KeyWrapper = (*CachingKeyProvider)(nil)        // ensure interface is implemented
+var _ aeadCacher = (*CachingKeyProvider)(nil)        // ensure interface is implemented
+
+// NewCachingKeyProvider returns a CachingKeyProvider for provider
+func NewCachingKeyProvider(provider KeyProvider, options CachingKeyProviderOptions) *CachingKeyProvider {
+	if options.TTL <= 0 {
+		options.TTL = 5 * time.Minute
+	}
+	if options.RefreshAfter <= 0 || options.RefreshAfter > options.TTL {
+		options.RefreshAfter = options.TTL / 2
+	}
+	if options.NegativeTTL <= 0 {
+		options.NegativeTTL = 30 * time.Second
+	}
+	if options.MaxStale == 0 {
+		options.MaxStale = 15 * time.Minute
+	}
+
+	return &CachingKeyProvider{
+		provider: provider,
+		options:  options,
+		now:      time.Now,
+		entries:  map[string]*cachedKey{},
+		dataKeys: map[dataKeyID]*cachedKey{},
+	}
+}
+
+func (p *CachingKeyProvider) GetKey(keyID string) ([]byte, error) {
+	var cached []byte
+	var age time.Duration
+	p.mu.Lock()
+	entry, ok := p.entries[keyID]
+	if ok {
+		// a copy taken under the lock, ShredKey zeroes entry.key
+		cached = bytes.Clone(entry.key)
+		age = p.now().Sub(entry.fetchedAt)
+	}
+	p.mu.Unlock()
+
+	if ok {
+		switch {
+		case entry.err != nil && age < p.options.NegativeTTL:
+			return nil, entry.err
+		case entry.err == nil && age < p.options.TTL:
+			if age >= p.options.RefreshAfter {
+				p.refresh(keyID, entry)
+			}
+			return cached, nil
+		}
+	}
+
+	key, err := p.fetch(keyID)
+	if err != nil && ok && entry.err == nil && !isPermanentKeyError(err) && age < p.options.TTL+p.options.MaxStale {
+		return cached, nil
+	}
+
+	return key, err
+}
+
+// refresh fetches the key in the background, once at a time
+func (p *CachingKeyProvider) refresh(keyID string, entry *cachedKey) {
+	p.mu.Lock()
+	if entry.refreshing {
+		p.mu.Unlock()
+		return
+	}
+	entry.refreshing = true
+	p.mu.Unlock()
+
+	go func() {
+		if _, err := p.fetch(keyID); err != nil {
+			// the cached key is kept, the next lookup tries again
+			p.mu.Lock()
+			entry.refreshing = false
+			p.mu.Unlock()
+		}
+	}()
+}
+
+// fetch gets the key from the provider and caches it, concurrent fetches of a key share one call
+func (p *CachingKeyProvider) fetch(keyID string) ([]byte, error) {
+	v, err, _ := p.group.Do(keyID, func() (interface{}, error) {
+		key, err := p.provider.GetKey(keyID)
+		if err != nil && !isPermanentKeyError(err) {
+			return nil, err
+		}
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		// a shredded key stays shredded, this fetch may have started before ShredKey
+		if current, ok := p.entries[keyID]; ok && errors.Is(current.err, ErrKeyShredded) && err == nil {
+			return nil, current.err
+		}
+		p.entries[keyID] = &cachedKey{key: bytes.Clone(key), err: err, fetchedAt: p.now()}
+
+		return key, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// concurrent callers share v
+	return bytes.Clone(v.([]byte)), nil
+}
+
+// ActiveKeyID returns the active key of the provider, if it's an ActiveKeyProvider
+func (p *CachingKeyProvider) ActiveKeyID() string {
+	if active, ok := p.provider.(ActiveKeyProvider); ok {
+		return active.ActiveKeyID()
+	}
+
+	return ""
+}
+
+func (p *CachingKeyProvider) wrapsKeys() bool {
+	_, ok := keyWrapper(p.provider)
+	return ok
+}
+
+// WrapKey wraps the data key with the provider, if it's a KeyWrapper.
+// The data key is cached, so the payloads it encrypts decode without unwrapping it.
+func (p *CachingKeyProvider) WrapKey(keyID string, dataKey []byte) ([]byte, error) {
+	w, ok := keyWrapper(p.provider)
+	if !ok {
+		return nil, fmt.Errorf("key provider %T doesn't wrap keys", p.provider)
+	}
+
+	wrappedKey, err := w.WrapKey(keyID, dataKey)
+	if err != nil {
+		return nil, err
+	}
+	p.cacheDataKey(dataKeyID{keyID: keyID, wrappedKey: string(wrappedKey)}, dataKey)
+
+	return wrappedKey, nil
+}
+
+// UnwrapKey unwraps the data key with the provider, if it's a KeyWrapper.
+// Data keys are cached like keys, a stale one is returned while the provider fails, up to MaxStale.
+func (p *CachingKeyProvider) UnwrapKey(keyID string, wrappedKey []byte) ([]byte, error) {
+	w, ok := keyWrapper(p.provider)
+	if !ok {
+		return nil, fmt.Errorf("key provider %T doesn't wrap keys", p.provider)
+	}
+	id := dataKeyID{keyID: keyID, wrappedKey: string(wrappedKey)}
+
+	var cached []byte
+	var age time.Duration
+	p.mu.Lock()
+	if current, ok := p.entries[keyID]; ok && errors.Is(current.err, ErrKeyShredded) {
+		p.mu.Unlock()
+		return nil, current.err
+	}
+	entry, ok := p.dataKeys[id]
+	if ok {
+		// a copy taken under the lock, ShredKey zeroes entry.key
+		cached = bytes.Clone(entry.key)
+		age = p.now().Sub(entry.fetchedAt)
+	}
+	p.mu.Unlock()
+
+	if ok && age < p.options.TTL {
+		return cached, nil
+	}
+
+	dataKey, err := w.UnwrapKey(keyID, wrappedKey)
+	if err != nil && ok && !isPermanentKeyError(err) && age < p.options.TTL+p.options.MaxStale {
+		return cached, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.cacheDataKey(id, dataKey)
+
+	return dataKey, nil
+}
+
+// cacheDataKey caches an unwrapped data key, unless its key was shredded meanwhile.
+// Data keys past their TTL and MaxStale are evicted at most once per TTL.
+func (p *CachingKeyProvider) cacheDataKey(id dataKeyID, dataKey []byte) {
+	now := p.now()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if current, ok := p.entries[id.keyID]; ok && errors.Is(current.err, ErrKeyShredded) {
+		return
+	}
+	if now.Sub(p.lastEvict) > p.options.TTL {
+		for cachedID, entry := range p.dataKeys {
+			if age := now.Sub(entry.fetchedAt); age >= p.options.TTL && age >= p.options.TTL+p.options.MaxStale {
+				zero(entry.key)
+				delete(p.dataKeys, cachedID)
+			}
+		}
+		p.lastEvict = now
+	}
+	p.dataKeys[id] = &cachedKey{key: bytes.Clone(dataKey), fetchedAt: now}
+}
+
+// ShredKey shreds the key with the provider and drops it from the cache
+func (p *CachingKeyProvider) ShredKey(keyID string) error {
+	shredder, ok := p.provider.(KeyShredder)
+	if !ok {
+		return fmt.Errorf("key provider %T doesn't support shredding", p.provider)
+	}
+	if err := shredder.ShredKey(keyID); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	if entry, ok := p.entries[keyID]; ok {
+		zero(entry.key)
+	}
+	p.entries[keyID] = &cachedKey{err: &KeyShreddedError{KeyID: keyID, ShreddedAt: p.now().UTC()}, fetchedAt: p.now()}
+	for id, entry := range p.dataKeys {
+		if id.keyID == keyID {
+			zero(entry.key)
+			delete(p.dataKeys, id)
+		}
+	}
+	p.mu.Unlock()
+	p.aeads.forget(keyID)
+
+	return nil
+}
+
+func (p *CachingKeyProvider) aeadCache() *aeadCache {
+	return &p.aeads
+}
+
+// isPermanentKeyError reports whether err is an answer of the provider rather than a failure to reach it
+func isPermanentKeyError(err error) bool {
+	return errors.Is(err, ErrKeyNotFound) || errors.Is(err, ErrKeyShredded)
+}
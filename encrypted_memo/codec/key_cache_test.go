@@ -0,0 +1,276 @@
+package codec
+
+import (
+	"encoding/base64"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+)
+
+// testClock is the CachingKeyProvider clock of a test, it's read by the background refreshes too
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Add(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTestCachingKeyProvider(t *testing.T, kms *testKMS) (*CachingKeyProvider, *testClock) {
+	provider := NewHTTPKeyProvider(kms.URL)
+	provider.Token = "s3cr3t"
+	p := NewCachingKeyProvider(provider, CachingKeyProviderOptions{
+		TTL:          time.Minute,
+		RefreshAfter: 30 * time.Second,
+		NegativeTTL:  10 * time.Second,
+		MaxStale:     5 * time.Minute,
+	})
+	clock := &testClock{now: time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)}
+	p.now = clock.Now
+
+	return p, clock
+}
+
+func Test_CachingKeyProvider(t *testing.T) {
+	kms := newTestKMS(t, "s3cr3t", map[string][]byte{"key-1": testKey1})
+	p, clock := newTestCachingKeyProvider(t, kms)
+
+	for i := 0; i < 3; i++ {
+		key, err := p.GetKey("key-1")
+		require.NoError(t, err)
+		require.Equal(t, testKey1, key)
+	}
+	require.Equal(t, 1, kms.callCount("/keys/key-1"))
+
+	// past RefreshAfter the cached key is returned and refreshed in the background
+	clock.Add(40 * time.Second)
+	key, err := p.GetKey("key-1")
+	require.NoError(t, err)
+	require.Equal(t, testKey1, key)
+	require.Eventually(t, func() bool { return kms.callCount("/keys/key-1") == 2 }, time.Second, 5*time.Millisecond)
+
+	// the refreshed key is fresh for another TTL
+	clock.Add(20 * time.Second)
+	_, err = p.GetKey("key-1")
+	require.NoError(t, err)
+	require.Equal(t, 2, kms.callCount("/keys/key-1"))
+
+	// past the TTL the key is fetched right away
+	clock.Add(2 * time.Minute)
+	_, err = p.GetKey("key-1")
+	require.NoError(t, err)
+	require.Equal(t, 3, kms.callCount("/keys/key-1"))
+
+	requireRoundTrip(t, p, "key-1")
+}
+
+func Test_CachingKeyProviderNegative(t *testing.T) {
+	kms := newTestKMS(t, "s3cr3t", map[string][]byte{"key-1": testKey1})
+	p, clock := newTestCachingKeyProvider(t, kms)
+
+	for i := 0; i < 3; i++ {
+		_, err := p.GetKey("missing")
+		require.ErrorIs(t, err, ErrKeyNotFound)
+	}
+	require.Equal(t, 1, kms.callCount("/keys/missing"))
+
+	clock.Add(11 * time.Second)
+	_, err := p.GetKey("missing")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+	require.Equal(t, 2, kms.callCount("/keys/missing"))
+
+	// outages aren't cached
+	kms.setDown(true)
+	_, err = p.GetKey("other")
+	require.ErrorContains(t, err, "503")
+	_, err = p.GetKey("other")
+	require.ErrorContains(t, err, "503")
+	require.Equal(t, 2, kms.callCount("/keys/other"))
+}
+
+func Test_CachingKeyProviderOutage(t *testing.T) {
+	kms := newTestKMS(t, "s3cr3t", map[string][]byte{"key-1": testKey1})
+	p, clock := newTestCachingKeyProvider(t, kms)
+	c := &Codec{KeyProvider: p, KeyID: "key-1"}
+
+	encoded, err := c.Encode([]*commonpb.Payload{{Data: []byte("before the outage")}})
+	require.NoError(t, err)
+
+	kms.setDown(true)
+
+	// the background refresh fails, the cached key is kept
+	clock.Add(40 * time.Second)
+	_, err = c.Encode([]*commonpb.Payload{{Data: []byte("during the outage")}})
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return kms.callCount("/keys/key-1") == 2 }, time.Second, 5*time.Millisecond)
+
+	// past the TTL the stale key is used while the KMS is down
+	clock.Add(2 * time.Minute)
+	decoded, err := c.Decode(encoded)
+	require.NoError(t, err)
+	require.Equal(t, "before the outage", string(decoded[0].GetData()))
+
+	// up to MaxStale
+	clock.Add(5 * time.Minute)
+	_, err = c.Decode(encoded)
+	require.ErrorContains(t, err, "503")
+
+	// the KMS is back
+	kms.setDown(false)
+	decoded, err = c.Decode(encoded)
+	require.NoError(t, err)
+	require.Equal(t, "before the outage", string(decoded[0].GetData()))
+}
+
+func Test_CachingKeyProviderSingleFlight(t *testing.T) {
+	kms := newTestKMS(t, "s3cr3t", map[string][]byte{"key-1": testKey1})
+	kms.delay = 50 * time.Millisecond
+	p, _ := newTestCachingKeyProvider(t, kms)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			key, err := p.GetKey("key-1")
+			require.NoError(t, err)
+			require.Equal(t, testKey1, key)
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, 1, kms.callCount("/keys/key-1"))
+}
+
+func Test_CachingKeyProviderShred(t *testing.T) {
+	kms := newTestKMS(t, "s3cr3t", map[string][]byte{"key-1": testKey1})
+	p, _ := newTestCachingKeyProvider(t, kms)
+
+	held, err := p.GetKey("key-1")
+	require.NoError(t, err)
+
+	// lookups racing the shred only ever see a whole key or the tombstone
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if key, err := p.GetKey("key-1"); err == nil {
+				require.Equal(t, testKey1, key)
+			}
+		}()
+	}
+	require.NoError(t, p.ShredKey("key-1"))
+	wg.Wait()
+	require.Equal(t, testKey1, held, "keys returned before are copies")
+	calls := kms.callCount("/keys/key-1") // the GET and the DELETE
+	_, err = p.GetKey("key-1")
+	require.ErrorIs(t, err, ErrKeyShredded)
+	require.Equal(t, calls, kms.callCount("/keys/key-1"), "the tombstone is cached")
+}
+
+func Test_CachingKeyProviderEnvelope(t *testing.T) {
+	kms := newTestKMS(t, "s3cr3t", map[string][]byte{"key-1": testKey1})
+	p, _ := newTestCachingKeyProvider(t, kms)
+
+	// the KMS still wraps the data keys
+	dc := NewEncryptionDataConverter(converter.GetDefaultDataConverter(), DataConverterOptions{
+		KeyID:              "key-1",
+		KeyProvider:        p,
+		EnvelopeEncryption: true,
+	})
+	payload, err := dc.ToPayload("secret")
+	require.NoError(t, err)
+	var result string
+	require.NoError(t, dc.FromPayload(payload, &result))
+	require.Equal(t, "secret", result)
+	require.Equal(t, 1, kms.callCount("/keys/key-1/wrap"))
+	require.Zero(t, kms.callCount("/keys/key-1/unwrap"), "the data key it wrapped is cached")
+	require.Zero(t, kms.callCount("/keys/key-1"), "the master key never leaves the KMS")
+
+	// another worker unwraps each data key once
+	other, _ := newTestCachingKeyProvider(t, kms)
+	otherDC := NewEncryptionDataConverter(converter.GetDefaultDataConverter(), DataConverterOptions{
+		KeyID:              "key-1",
+		KeyProvider:        other,
+		EnvelopeEncryption: true,
+	})
+	for i := 0; i < 3; i++ {
+		require.NoError(t, otherDC.FromPayload(payload, &result))
+		require.Equal(t, "secret", result)
+	}
+	require.Equal(t, 1, kms.callCount("/keys/key-1/unwrap"))
+
+	// providers without a KeyWrapper wrap locally with the cached master key
+	t.Setenv("TEMPORAL_ENCRYPTION_KEY_KEY_1", base64.StdEncoding.EncodeToString(testKey1))
+	local := NewEncryptionDataConverter(converter.GetDefaultDataConverter(), DataConverterOptions{
+		KeyID:              "key-1",
+		KeyProvider:        NewCachingKeyProvider(NewEnvKeyProvider(), CachingKeyProviderOptions{}),
+		EnvelopeEncryption: true,
+	})
+	payload, err = local.ToPayload("local")
+	require.NoError(t, err)
+	require.NotEmpty(t, payload.GetMetadata()[MetadataEncryptionDataKey])
+	require.NoError(t, local.FromPayload(payload, &result))
+	require.Equal(t, "local", result)
+}
+
+func Test_CachingKeyProviderEnvelopeOutage(t *testing.T) {
+	kms := newTestKMS(t, "s3cr3t", map[string][]byte{"key-1": testKey1})
+	writer, _ := newTestCachingKeyProvider(t, kms)
+	encoded, err := (&Codec{KeyProvider: writer, KeyID: "key-1", Envelope: true}).Encode([]*commonpb.Payload{{Data: []byte("before the outage")}})
+	require.NoError(t, err)
+
+	p, clock := newTestCachingKeyProvider(t, kms)
+	c := &Codec{KeyProvider: p, KeyID: "key-1", Envelope: true}
+	_, err = c.Decode(encoded)
+	require.NoError(t, err)
+
+	kms.setDown(true)
+
+	// within the TTL the data key is cached
+	clock.Add(40 * time.Second)
+	decoded, err := c.Decode(encoded)
+	require.NoError(t, err)
+	require.Equal(t, "before the outage", string(decoded[0].GetData()))
+
+	// past the TTL the stale data key is used while the KMS is down
+	clock.Add(2 * time.Minute)
+	decoded, err = c.Decode(encoded)
+	require.NoError(t, err)
+	require.Equal(t, "before the outage", string(decoded[0].GetData()))
+
+	// new batches are wrapped by the KMS
+	_, err = c.Encode([]*commonpb.Payload{{Data: []byte("during the outage")}})
+	require.ErrorContains(t, err, "503")
+
+	// up to MaxStale
+	clock.Add(5 * time.Minute)
+	_, err = c.Decode(encoded)
+	require.ErrorContains(t, err, "503")
+
+	// the KMS is back
+	kms.setDown(false)
+	decoded, err = c.Decode(encoded)
+	require.NoError(t, err)
+	require.Equal(t, "before the outage", string(decoded[0].GetData()))
+
+	// shredding drops the data keys of the key
+	require.NoError(t, p.ShredKey("key-1"))
+	_, err = p.UnwrapKey("key-1", encoded[0].GetMetadata()[MetadataEncryptionDataKey])
+	require.ErrorIs(t, err, ErrKeyShredded)
+	require.Empty(t, p.dataKeys)
+}
@@ -0,0 +1,82 @@
+package codec
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ErrKeyNotFound is returned by a KeyProvider that doesn't know the key ID
+var ErrKeyNotFound = errors.New("encryption key not found")
+
+// ErrInvalidKey is returned by a KeyProvider for key material that isn't KeySize bytes
+var ErrInvalidKey = errors.New("invalid encryption key")
+
+// KeySize is the size of the keys of every Cipher, 256 bits
+const KeySize = 32
+
+// KeyProvider resolves a key ID, as stored in the payload metadata under MetadataEncryptionKeyID, to key material.
+// Keys must be KeySize bytes.
+type KeyProvider interface {
+	GetKey(keyID string) ([]byte, error)
+}
+
+// checkKeySize returns an ErrInvalidKey for a key that isn't KeySize bytes
+func checkKeySize(keyID string, key []byte) error {
+	if len(key) != KeySize {
+		return fmt.Errorf("%w: %s is %d bytes, want %d", ErrInvalidKey, keyID, len(key), KeySize)
+	}
+
+	return nil
+}
+
+// testKeyProvider is used when no KeyProvider is configured.
+type testKeyProvider struct{}
+
+func (testKeyProvider) GetKey(string) ([]byte, error) {
+	// Key must be fetched from secure storage in production (such as a KMS).
+	// For testing here we just hard code a key.
+	return []byte("test-key-test-key-test-key-test!"), nil
+}
+
+// EnvKeyProvider reads base64 encoded keys from environment variables.
+// The variable name is the Prefix followed by the key ID upper cased, with anything
+// that's not a letter or digit replaced by "_", e.g. key ID "tenant-1" is TEMPORAL_ENCRYPTION_KEY_TENANT_1.
+type EnvKeyProvider struct {
+	Prefix string
+}
+
+// NewEnvKeyProvider returns an EnvKeyProvider using the TEMPORAL_ENCRYPTION_KEY_ prefix
+func NewEnvKeyProvider() *EnvKeyProvider {
+	return &EnvKeyProvider{Prefix: "TEMPORAL_ENCRYPTION_KEY_"}
+}
+
+func (p *EnvKeyProvider) GetKey(keyID string) ([]byte, error) {
+	name := p.Prefix + strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, keyID)
+
+	v, ok := os.LookupEnv(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is not set", ErrKeyNotFound, name)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(v)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key in %s: %w", name, err)
+	}
+	if err := checkKeySize(keyID, key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
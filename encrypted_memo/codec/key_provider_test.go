@@ -0,0 +1,263 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/converter"
+)
+
+var (
+	testKey1 = []byte("key-1-key-1-key-1-key-1-key-1-!!")
+	testKey2 = []byte("key-2-key-2-key-2-key-2-key-2-!!")
+)
+
+// requireRoundTrip encrypts with the provider and makes sure it decrypts with the same provider
+func requireRoundTrip(t *testing.T, provider KeyProvider, keyID string) {
+	t.Helper()
+
+	dc := NewEncryptionDataConverter(converter.GetDefaultDataConverter(), DataConverterOptions{
+		KeyID:       keyID,
+		KeyProvider: provider,
+	})
+
+	p, err := dc.ToPayload("secret")
+	require.NoError(t, err)
+	require.Equal(t, keyID, string(p.GetMetadata()[MetadataEncryptionKeyID]))
+	require.NotContains(t, string(p.GetData()), "secret")
+
+	var result string
+	require.NoError(t, dc.FromPayload(p, &result))
+	require.Equal(t, "secret", result)
+}
+
+func Test_EnvKeyProvider(t *testing.T) {
+	t.Setenv("TEMPORAL_ENCRYPTION_KEY_TENANT_1", base64.StdEncoding.EncodeToString(testKey1))
+
+	p := NewEnvKeyProvider()
+	key, err := p.GetKey("tenant-1")
+	require.NoError(t, err)
+	require.Equal(t, testKey1, key)
+
+	_, err = p.GetKey("tenant-2")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+
+	requireRoundTrip(t, p, "tenant-1")
+}
+
+func Test_FileKeyProvider(t *testing.T) {
+	dir := t.TempDir()
+
+	jsonFile := filepath.Join(dir, "keyring.json")
+	b, err := json.Marshal(keyringFile{Active: "key-2", Keys: map[string][]byte{"key-1": testKey1, "key-2": testKey2}})
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(jsonFile, b, 0600))
+
+	pemFile := filepath.Join(dir, "keyring.pem")
+	pemData := append(EncodeKeyPEM("key-1", testKey1), EncodeKeyPEM("key-2", testKey2)...)
+	pemData = bytes.Replace(pemData, []byte("Key-Id: key-2\n"), []byte("Key-Id: key-2\nActive: true\n"), 1)
+	require.NoError(t, os.WriteFile(pemFile, pemData, 0600))
+
+	for _, file := range []string{jsonFile, pemFile} {
+		t.Run(filepath.Ext(file), func(t *testing.T) {
+			p, err := NewFileKeyProvider(file)
+			require.NoError(t, err)
+
+			require.Equal(t, "key-2", p.ActiveKeyID())
+
+			key, err := p.GetKey("key-2")
+			require.NoError(t, err)
+			require.Equal(t, testKey2, key)
+
+			_, err = p.GetKey("key-3")
+			require.ErrorIs(t, err, ErrKeyNotFound)
+
+			requireRoundTrip(t, p, "key-1")
+		})
+	}
+}
+
+// testKMS is a local stand-in for a KMS serving GET /keys/{keyID} and POST /keys/{keyID}/(wrap|unwrap)
+type testKMS struct {
+	*httptest.Server
+	token string
+
+	mu       sync.Mutex
+	keys     map[string][]byte
+	shredded map[string]time.Time
+	calls    map[string]int // by request path
+	down     bool           // simulates an outage with 503s
+	delay    time.Duration  // before each response
+}
+
+func newTestKMS(t *testing.T, token string, keys map[string][]byte) *testKMS {
+	kms := &testKMS{token: token, keys: keys, shredded: map[string]time.Time{}, calls: map[string]int{}}
+	kms.Server = httptest.NewServer(kms)
+	t.Cleanup(kms.Close)
+
+	return kms
+}
+
+func (kms *testKMS) callCount(path string) int {
+	kms.mu.Lock()
+	defer kms.mu.Unlock()
+	return kms.calls[path]
+}
+
+func (kms *testKMS) setDown(down bool) {
+	kms.mu.Lock()
+	defer kms.mu.Unlock()
+	kms.down = down
+}
+
+func (kms *testKMS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	kms.mu.Lock()
+	kms.calls[r.URL.Path]++
+	down, delay := kms.down, kms.delay
+	kms.mu.Unlock()
+
+	time.Sleep(delay)
+	if down {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	kms.mu.Lock()
+	defer kms.mu.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer "+kms.token {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/keys/")
+	keyID, op, _ := strings.Cut(path, "/")
+	if at, ok := kms.shredded[keyID]; ok {
+		w.WriteHeader(http.StatusGone)
+		_ = json.NewEncoder(w).Encode(KMSKeyResponse{KeyID: keyID, ShreddedAt: &at})
+		return
+	}
+	key, ok := kms.keys[keyID]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodDelete && op == "":
+		delete(kms.keys, keyID)
+		kms.shredded[keyID] = time.Now().UTC().Truncate(time.Second)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet && op == "":
+		_ = json.NewEncoder(w).Encode(KMSKeyResponse{KeyID: keyID, Key: key})
+	case r.Method == http.MethodPost && (op == "wrap" || op == "unwrap"):
+		var req KMSWrapRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		aead, err := newAEAD(CipherAES256GCM, key)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		var resp KMSWrapRequest
+		if op == "wrap" {
+			resp.Ciphertext, err = seal(aead, req.Plaintext, nil)
+		} else {
+			resp.Plaintext, err = open(aead, req.Ciphertext, nil)
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func Test_HTTPKeyProvider(t *testing.T) {
+	srv := newTestKMS(t, "s3cr3t", map[string][]byte{"key-1": testKey1})
+
+	p := NewHTTPKeyProvider(srv.URL)
+	_, err := p.GetKey("key-1")
+	require.ErrorContains(t, err, "401")
+
+	p.Token = "s3cr3t"
+	key, err := p.GetKey("key-1")
+	require.NoError(t, err)
+	require.Equal(t, testKey1, key)
+
+	_, err = p.GetKey("key-2")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+
+	requireRoundTrip(t, p, "key-1")
+}
+
+func Test_KeyProvidersRejectInvalidKeys(t *testing.T) {
+	t.Setenv("TEMPORAL_ENCRYPTION_KEY_SHORT", base64.StdEncoding.EncodeToString([]byte("too-short")))
+	_, err := NewEnvKeyProvider().GetKey("short")
+	require.ErrorIs(t, err, ErrInvalidKey)
+
+	_, err = NewKeyring("short", map[string][]byte{"short": []byte("too-short")})
+	require.ErrorIs(t, err, ErrInvalidKey)
+
+	srv := newTestKMS(t, "s3cr3t", map[string][]byte{"short": []byte("too-short")})
+	p := NewHTTPKeyProvider(srv.URL)
+	p.Token = "s3cr3t"
+	_, err = p.GetKey("short")
+	require.ErrorIs(t, err, ErrInvalidKey)
+
+	// a KMS answering with another key than the one asked for
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(KMSKeyResponse{KeyID: "key-2", Key: testKey2})
+	}))
+	t.Cleanup(other.Close)
+	_, err = NewHTTPKeyProvider(other.URL).GetKey("key-1")
+	require.ErrorIs(t, err, ErrInvalidKey)
+}
+
+func Test_ScopedKeyProvider(t *testing.T) {
+	kms := newTestKMS(t, "s3cr3t", map[string][]byte{"key-1": testKey1, "key-2": testKey2})
+	provider := NewHTTPKeyProvider(kms.URL)
+	provider.Token = "s3cr3t"
+
+	scoped := NewScopedKeyProvider(provider, []string{"key-1"})
+	require.Implements(t, (*KeyWrapper)(nil), scoped, "the KeyWrapper of the provider is kept")
+	requireRoundTrip(t, scoped, "key-1")
+	_, err := scoped.GetKey("key-2")
+	require.ErrorIs(t, err, ErrKeyNotAllowed)
+
+	// payloads of other keys don't decode, wrapped or not
+	for _, envelope := range []bool{false, true} {
+		dc := NewEncryptionDataConverter(converter.GetDefaultDataConverter(), DataConverterOptions{
+			KeyID: "key-2", KeyProvider: provider, EnvelopeEncryption: envelope,
+		})
+		p, err := dc.ToPayload("secret")
+		require.NoError(t, err)
+
+		scopedDC := NewEncryptionDataConverter(converter.GetDefaultDataConverter(), DataConverterOptions{KeyProvider: scoped})
+		var result string
+		require.ErrorIs(t, scopedDC.FromPayload(p, &result), ErrKeyNotAllowed)
+	}
+
+	local := NewScopedKeyProvider(NewEnvKeyProvider(), []string{"key-1"})
+	_, ok := local.(KeyWrapper)
+	require.False(t, ok, "providers without a KeyWrapper wrap locally")
+
+	// nil is the test key
+	key, err := NewScopedKeyProvider(nil, []string{""}).GetKey("")
+	require.NoError(t, err)
+	require.Len(t, key, KeySize)
+}
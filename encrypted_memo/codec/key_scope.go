@@ -0,0 +1,82 @@
+package codec
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrKeyNotAllowed is returned by a scoped KeyProvider for a key ID outside of its scope
+var ErrKeyNotAllowed = errors.New("encryption key not allowed")
+
+// scopedKeyProvider only resolves the key IDs in its scope
+type scopedKeyProvider struct {
+	provider KeyProvider
+	keyIDs   map[string]bool
+}
+
+// scopedKeyWrapper is a scopedKeyProvider of a KeyWrapper, the Codec finds the KeyWrapper by type assertion
+type scopedKeyWrapper struct {
+	scopedKeyProvider
+}
+
+var _ KeyWrapper = (*scopedKeyWrapper)(nil) // ensure interface is implemented
+
+// NewScopedKeyProvider returns a KeyProvider that only resolves keyIDs, e.g. the keys of one namespace in a
+// codec server shared by several. A nil provider is the hard coded test key.
+// When provider is a KeyWrapper the returned KeyProvider is one too, unwrapping only with keyIDs.
+func NewScopedKeyProvider(provider KeyProvider, keyIDs []string) KeyProvider {
+	if provider == nil {
+		provider = testKeyProvider{}
+	}
+	scoped := scopedKeyProvider{provider: provider, keyIDs: make(map[string]bool, len(keyIDs))}
+	for _, keyID := range keyIDs {
+		scoped.keyIDs[keyID] = true
+	}
+
+	if _, ok := keyWrapper(provider); ok {
+		return &scopedKeyWrapper{scoped}
+	}
+
+	return &scoped
+}
+
+func (p *scopedKeyProvider) check(keyID string) error {
+	if !p.keyIDs[keyID] {
+		return fmt.Errorf("%w: %s", ErrKeyNotAllowed, keyID)
+	}
+
+	return nil
+}
+
+func (p *scopedKeyProvider) GetKey(keyID string) ([]byte, error) {
+	if err := p.check(keyID); err != nil {
+		return nil, err
+	}
+
+	return p.provider.GetKey(keyID)
+}
+
+// aeadCache is the cache of the scoped provider, so shredding with it clears the scoped codecs' AEADs too
+func (p *scopedKeyProvider) aeadCache() *aeadCache {
+	if c, ok := p.provider.(aeadCacher); ok {
+		return c.aeadCache()
+	}
+
+	return nil
+}
+
+func (p *scopedKeyWrapper) WrapKey(keyID string, dataKey []byte) ([]byte, error) {
+	if err := p.check(keyID); err != nil {
+		return nil, err
+	}
+
+	return p.provider.(KeyWrapper).WrapKey(keyID, dataKey)
+}
+
+func (p *scopedKeyWrapper) UnwrapKey(keyID string, wrappedKey []byte) ([]byte, error) {
+	if err := p.check(keyID); err != nil {
+		return nil, err
+	}
+
+	return p.provider.(KeyWrapper).UnwrapKey(keyID, wrappedKey)
+}
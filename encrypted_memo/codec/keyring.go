@@ -0,0 +1,107 @@
+package codec
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ActiveKeyProvider is a KeyProvider that also knows which key new payloads should be encrypted with.
+//
+// When a Codec has no KeyID configured it encrypts with the ActiveKeyID, while Decode keeps using whatever
+// key ID is stored in the payload metadata. This allows keys to be rotated without breaking old histories.
+type ActiveKeyProvider interface {
+	KeyProvider
+	ActiveKeyID() string
+}
+
+// Keyring encrypts with the active key and decrypts with any known key
+type Keyring struct {
+	active string
+
+	mu       sync.RWMutex
+	keys     map[string][]byte
+	shredded map[string]time.Time
+
+	aeads aeadCache
+}
+
+var _ ActiveKeyProvider = (*Keyring)(nil) // ensure interface is implemented
+var _ KeyShredder = (*Keyring)(nil)       // ensure interface is implemented
+var _ aeadCacher = (*Keyring)(nil)        // ensure interface is implemented
+
+// NewKeyring returns a Keyring, the active key ID must be one of the keys
+func NewKeyring(active string, keys map[string][]byte) (*Keyring, error) {
+	if _, ok := keys[active]; !ok {
+		return nil, fmt.Errorf("active key %q is not in the keyring", active)
+	}
+	for keyID, key := range keys {
+		if err := checkKeySize(keyID, key); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Keyring{active: active, keys: keys, shredded: map[string]time.Time{}}, nil
+}
+
+func (k *Keyring) ActiveKeyID() string {
+	return k.active
+}
+
+func (k *Keyring) GetKey(keyID string) ([]byte, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if at, ok := k.shredded[keyID]; ok {
+		return nil, &KeyShreddedError{KeyID: keyID, ShreddedAt: at}
+	}
+	key, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, keyID)
+	}
+
+	// a copy, so ShredKey can zero the key while callers still hold it
+	return bytes.Clone(key), nil
+}
+
+// KeyIDs returns every key ID in the keyring, including retired ones that are only used to decrypt
+func (k *Keyring) KeyIDs() []string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	ids := make([]string, 0, len(k.keys))
+	for id := range k.keys {
+		ids = append(ids, id)
+	}
+
+	return ids
+}
+
+// ShredKey destroys the key and keeps a tombstone, the active key can't be shredded
+func (k *Keyring) ShredKey(keyID string) error {
+	if keyID == k.active {
+		return fmt.Errorf("can't shred the active key %q, rotate to another key first", keyID)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if _, ok := k.shredded[keyID]; ok {
+		return nil
+	}
+	key, ok := k.keys[keyID]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrKeyNotFound, keyID)
+	}
+	k.aeads.forget(keyID)
+	delete(k.keys, keyID)
+	k.shredded[keyID] = time.Now().UTC()
+	zero(key)
+
+	return nil
+}
+
+func (k *Keyring) aeadCache() *aeadCache {
+	return &k.aeads
+}
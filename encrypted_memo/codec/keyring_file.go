@@ -0,0 +1,254 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// PEMBlockType is the PEM block type of a key in a keyring file
+	PEMBlockType = "TEMPORAL ENCRYPTION KEY"
+
+	// PEMHeaderKeyID is the PEM header holding the key ID
+	PEMHeaderKeyID = "Key-Id"
+
+	// PEMHeaderActive marks the active key with "Active: true"
+	PEMHeaderActive = "Active"
+
+	// PEMHeaderShreddedAt is the RFC 3339 time a key was shredded, the block of a shredded key has no key material
+	PEMHeaderShreddedAt = "Shredded-At"
+)
+
+// FileKeyProvider is a keyring loaded from a local file.
+//
+// The file is either json, with base64 encoded keys:
+//
+//	{"active": "key-1", "keys": {"key-1": "dGVzdC1rZXktdGVzdC1rZXktdGVzdC1rZXktdGVzdCE="}}
+//
+// or a list of PEM blocks:
+//
+//	-----BEGIN TEMPORAL ENCRYPTION KEY-----
+//	Key-Id: key-1
+//	Active: true
+//
+//	dGVzdC1rZXktdGVzdC1rZXktdGVzdC1rZXktdGVzdCE=
+//	-----END TEMPORAL ENCRYPTION KEY-----
+//
+// The active key is optional, when set new payloads are encrypted with it. See ActiveKeyProvider.
+//
+// ShredKey rewrites the file without the key, keeping a tombstone under "shredded" or a PEM block with
+// a Shredded-At header. Other processes that loaded the file, like running workers and codec servers, keep the key
+// in memory until they're restarted.
+type FileKeyProvider struct {
+	path string
+	pem  bool
+
+	mu sync.RWMutex
+	keyringFile
+
+	aeads aeadCache
+}
+
+var _ ActiveKeyProvider = (*FileKeyProvider)(nil) // ensure interface is implemented
+var _ KeyShredder = (*FileKeyProvider)(nil)       // ensure interface is implemented
+var _ aeadCacher = (*FileKeyProvider)(nil)        // ensure interface is implemented
+
+// keyringFile is the json layout of a keyring file, json encodes []byte as base64
+type keyringFile struct {
+	Active   string               `json:"active,omitempty"`
+	Keys     map[string][]byte    `json:"keys"`
+	Shredded map[string]time.Time `json:"shredded,omitempty"`
+}
+
+// NewFileKeyProvider loads a json or PEM keyring file
+func NewFileKeyProvider(path string) (*FileKeyProvider, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyring: %w", err)
+	}
+
+	f, err := parseKeyring(b)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse keyring %s: %w", path, err)
+	}
+	if _, ok := f.Keys[f.Active]; f.Active != "" && !ok {
+		return nil, fmt.Errorf("active key %q is not in the keyring %s", f.Active, path)
+	}
+	for keyID, key := range f.Keys {
+		if err := checkKeySize(keyID, key); err != nil {
+			return nil, fmt.Errorf("keyring %s: %w", path, err)
+		}
+	}
+
+	return &FileKeyProvider{path: path, pem: isPEM(b), keyringFile: f}, nil
+}
+
+func (p *FileKeyProvider) ActiveKeyID() string {
+	return p.Active
+}
+
+func (p *FileKeyProvider) GetKey(keyID string) ([]byte, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if at, ok := p.Shredded[keyID]; ok {
+		return nil, &KeyShreddedError{KeyID: keyID, ShreddedAt: at}
+	}
+	key, ok := p.Keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, keyID)
+	}
+
+	// a copy, so ShredKey can zero the key while callers still hold it
+	return bytes.Clone(key), nil
+}
+
+// ShredKey destroys the key and rewrites the keyring file with a tombstone, the active key can't be shredded
+func (p *FileKeyProvider) ShredKey(keyID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if keyID == p.Active {
+		return fmt.Errorf("can't shred the active key %q, rotate to another key first", keyID)
+	}
+	if _, ok := p.Shredded[keyID]; ok {
+		return nil
+	}
+	key, ok := p.Keys[keyID]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrKeyNotFound, keyID)
+	}
+
+	// the file is written first, the key stays usable when that fails
+	shredded := keyringFile{Active: p.Active, Keys: map[string][]byte{}, Shredded: map[string]time.Time{}}
+	for id, k := range p.Keys {
+		if id != keyID {
+			shredded.Keys[id] = k
+		}
+	}
+	for id, at := range p.Shredded {
+		shredded.Shredded[id] = at
+	}
+	shredded.Shredded[keyID] = time.Now().UTC().Truncate(time.Second)
+	if err := p.save(shredded); err != nil {
+		return err
+	}
+
+	p.keyringFile = shredded
+	p.aeads.forget(keyID)
+	zero(key)
+
+	return nil
+}
+
+// save writes f in the format the keyring was read in, through a temp file so it's never half written
+func (p *FileKeyProvider) aeadCache() *aeadCache {
+	return &p.aeads
+}
+
+func (p *FileKeyProvider) save(f keyringFile) error {
+	var b []byte
+	if p.pem {
+		for keyID, key := range f.Keys {
+			block := &pem.Block{Type: PEMBlockType, Headers: map[string]string{PEMHeaderKeyID: keyID}, Bytes: key}
+			if keyID == f.Active {
+				block.Headers[PEMHeaderActive] = "true"
+			}
+			b = append(b, pem.EncodeToMemory(block)...)
+		}
+		for keyID, at := range f.Shredded {
+			b = append(b, pem.EncodeToMemory(&pem.Block{
+				Type:    PEMBlockType,
+				Headers: map[string]string{PEMHeaderKeyID: keyID, PEMHeaderShreddedAt: at.Format(time.RFC3339)},
+			})...)
+		}
+	} else {
+		var err error
+		if b, err = json.MarshalIndent(f, "", "  "); err != nil {
+			return err
+		}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(p.path), filepath.Base(p.path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to save keyring: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to save keyring: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to save keyring: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), p.path); err != nil {
+		return fmt.Errorf("failed to save keyring: %w", err)
+	}
+
+	return nil
+}
+
+func isPEM(b []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(b), []byte("-----BEGIN"))
+}
+
+func parseKeyring(b []byte) (keyringFile, error) {
+	var f keyringFile
+	if !isPEM(b) {
+		err := json.Unmarshal(b, &f)
+		return f, err
+	}
+
+	f.Keys = map[string][]byte{}
+	for {
+		var block *pem.Block
+		block, b = pem.Decode(b)
+		if block == nil {
+			break
+		}
+		if block.Type != PEMBlockType {
+			continue
+		}
+
+		keyID, ok := block.Headers[PEMHeaderKeyID]
+		if !ok {
+			return f, fmt.Errorf("PEM block is missing the %s header", PEMHeaderKeyID)
+		}
+		if v, ok := block.Headers[PEMHeaderShreddedAt]; ok {
+			at, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return f, fmt.Errorf("invalid %s header of %q: %w", PEMHeaderShreddedAt, keyID, err)
+			}
+			if f.Shredded == nil {
+				f.Shredded = map[string]time.Time{}
+			}
+			f.Shredded[keyID] = at
+			continue
+		}
+		f.Keys[keyID] = block.Bytes
+		if block.Headers[PEMHeaderActive] == "true" {
+			f.Active = keyID
+		}
+	}
+
+	if len(f.Keys) == 0 && len(f.Shredded) == 0 {
+		return f, fmt.Errorf("no %q PEM blocks found", PEMBlockType)
+	}
+
+	return f, nil
+}
+
+// EncodeKeyPEM encodes a key as a PEM block for a keyring file
+func EncodeKeyPEM(keyID string, key []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{
+		Type:    PEMBlockType,
+		Headers: map[string]string{PEMHeaderKeyID: keyID},
+		Bytes:   key,
+	})
+}
@@ -0,0 +1,36 @@
+package codec
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/converter"
+)
+
+func Test_KeyringRotation(t *testing.T) {
+	before, err := NewKeyring("key-1", map[string][]byte{"key-1": testKey1})
+	require.NoError(t, err)
+	dc := NewEncryptionDataConverter(converter.GetDefaultDataConverter(), DataConverterOptions{KeyProvider: before})
+
+	old, err := dc.ToPayload("encrypted before the rotation")
+	require.NoError(t, err)
+	require.Equal(t, "key-1", string(old.GetMetadata()[MetadataEncryptionKeyID]))
+
+	// rotate, key-1 is retired but still known for decryption
+	after, err := NewKeyring("key-2", map[string][]byte{"key-1": testKey1, "key-2": testKey2})
+	require.NoError(t, err)
+	dc = NewEncryptionDataConverter(converter.GetDefaultDataConverter(), DataConverterOptions{KeyProvider: after})
+
+	p, err := dc.ToPayload("encrypted after the rotation")
+	require.NoError(t, err)
+	require.Equal(t, "key-2", string(p.GetMetadata()[MetadataEncryptionKeyID]))
+
+	var result string
+	require.NoError(t, dc.FromPayload(old, &result))
+	require.Equal(t, "encrypted before the rotation", result)
+	require.NoError(t, dc.FromPayload(p, &result))
+	require.Equal(t, "encrypted after the rotation", result)
+
+	_, err = NewKeyring("key-3", map[string][]byte{"key-1": testKey1})
+	require.Error(t, err)
+}
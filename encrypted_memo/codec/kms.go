@@ -0,0 +1,169 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// HTTPKeyProvider fetches keys from a KMS-style HTTP service.
+//
+// The key ID is requested with
+//
+//	GET {BaseURL}/keys/{keyID}
+//	Authorization: Bearer {Token}
+//
+// and the service responds with
+//
+//	{"keyId": "key-1", "key": "<base64 encoded key>"}
+//
+// The keyId must be the requested one and the key KeySize bytes, otherwise GetKey returns an ErrInvalidKey.
+//
+// A 404 is reported as ErrKeyNotFound, a 410 as a KeyShreddedError with the same body and a "shreddedAt" time.
+//
+// For envelope encryption, data keys are wrapped and unwrapped by the KMS so the master key never leaves it
+//
+//	POST {BaseURL}/keys/{keyID}/wrap    {"plaintext": "<base64>"} -> {"ciphertext": "<base64>"}
+//	POST {BaseURL}/keys/{keyID}/unwrap  {"ciphertext": "<base64>"} -> {"plaintext": "<base64>"}
+//
+// Keys are shredded with
+//
+//	DELETE {BaseURL}/keys/{keyID}
+type HTTPKeyProvider struct {
+	BaseURL string
+	// Token is sent as a bearer token when set
+	Token  string
+	Client *http.Client
+
+	aeads aeadCache
+}
+
+// KMSKeyResponse is the response body of GET /keys/{keyID}
+type KMSKeyResponse struct {
+	KeyID string `json:"keyId"`
+	Key   []byte `json:"key,omitempty"`
+	// ShreddedAt is set instead of the Key for shredded keys
+	ShreddedAt *time.Time `json:"shreddedAt,omitempty"`
+}
+
+// KMSWrapRequest is the body of the wrap and unwrap requests, and their responses
+type KMSWrapRequest struct {
+	Plaintext  []byte `json:"plaintext,omitempty"`
+	Ciphertext []byte `json:"ciphertext,omitempty"`
+}
+
+var _ KeyWrapper = (*HTTPKeyProvider)(nil)  // ensure interface is implemented
+var _ KeyShredder = (*HTTPKeyProvider)(nil) // ensure interface is implemented
+
+// NewHTTPKeyProvider returns an HTTPKeyProvider for the KMS at baseURL
+func NewHTTPKeyProvider(baseURL string) *HTTPKeyProvider {
+	return &HTTPKeyProvider{
+		BaseURL: strings.TrimSuffix(baseURL, "/"),
+		Client:  &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+func (p *HTTPKeyProvider) GetKey(keyID string) ([]byte, error) {
+	var resp KMSKeyResponse
+	if err := p.do(http.MethodGet, "/keys/"+url.PathEscape(keyID), nil, &resp); err != nil {
+		return nil, fmt.Errorf("kms: %s: %w", keyID, err)
+	}
+	if resp.KeyID != keyID {
+		return nil, fmt.Errorf("kms: %s: %w: the response is for key %q", keyID, ErrInvalidKey, resp.KeyID)
+	}
+	if err := checkKeySize(keyID, resp.Key); err != nil {
+		return nil, fmt.Errorf("kms: %w", err)
+	}
+
+	return resp.Key, nil
+}
+
+func (p *HTTPKeyProvider) WrapKey(keyID string, dataKey []byte) ([]byte, error) {
+	var resp KMSWrapRequest
+	err := p.do(http.MethodPost, "/keys/"+url.PathEscape(keyID)+"/wrap", KMSWrapRequest{Plaintext: dataKey}, &resp)
+	if err != nil {
+		return nil, fmt.Errorf("kms: wrap %s: %w", keyID, err)
+	}
+
+	return resp.Ciphertext, nil
+}
+
+func (p *HTTPKeyProvider) UnwrapKey(keyID string, wrappedKey []byte) ([]byte, error) {
+	var resp KMSWrapRequest
+	err := p.do(http.MethodPost, "/keys/"+url.PathEscape(keyID)+"/unwrap", KMSWrapRequest{Ciphertext: wrappedKey}, &resp)
+	if err != nil {
+		return nil, fmt.Errorf("kms: unwrap %s: %w", keyID, err)
+	}
+	if err := checkKeySize("data key of "+keyID, resp.Plaintext); err != nil {
+		return nil, fmt.Errorf("kms: unwrap %w", err)
+	}
+
+	return resp.Plaintext, nil
+}
+
+func (p *HTTPKeyProvider) ShredKey(keyID string) error {
+	if err := p.do(http.MethodDelete, "/keys/"+url.PathEscape(keyID), nil, nil); err != nil {
+		return fmt.Errorf("kms: shred %s: %w", keyID, err)
+	}
+	p.aeads.forget(keyID)
+
+	return nil
+}
+
+func (p *HTTPKeyProvider) aeadCache() *aeadCache {
+	return &p.aeads
+}
+
+func (p *HTTPKeyProvider) do(method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+
+	req, err := http.NewRequest(method, p.BaseURL+path, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if p.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.Token)
+	}
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrKeyNotFound
+	case resp.StatusCode == http.StatusGone:
+		var key KMSKeyResponse
+		shredded := &KeyShreddedError{}
+		if json.NewDecoder(resp.Body).Decode(&key) == nil && key.ShreddedAt != nil {
+			shredded.KeyID, shredded.ShreddedAt = key.KeyID, *key.ShreddedAt
+		}
+		return shredded
+	case resp.StatusCode == http.StatusNoContent:
+		return nil
+	case resp.StatusCode != http.StatusOK:
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,102 @@
+package codec
+
+import (
+	"context"
+	"fmt"
+
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/workflow"
+)
+
+type (
+	// contextKey is an unexported type used as key for items stored in the
+	// Context object
+	contextKey int
+
+	// propagator implements the custom context propagator
+	propagator struct{}
+)
+
+const (
+	_                   contextKey = iota
+	PropagatedValuesKey            // The key used to store PropagatedValues in the context
+)
+
+// propagationKey is the key used by the propagator to pass values through the
+// Temporal Workflow Event History headers
+const propagationKey = "encryption-context"
+
+// PropagatedValues is the struct stored on the context under PropagatedValuesKey.
+// DataConverter.WithContext and DataConverter.WithWorkflowContext pick the encryption key from it.
+type PropagatedValues struct {
+	TenantID string `json:"tenantID,omitempty"`
+}
+
+// NewContextPropagator returns a context propagator that propagates PropagatedValues across a workflow
+// and its activities
+func NewContextPropagator() workflow.ContextPropagator {
+	return &propagator{}
+}
+
+// Inject injects values from context into headers for propagation
+func (s *propagator) Inject(ctx context.Context, writer workflow.HeaderWriter) error {
+	vals, ok := ctx.Value(PropagatedValuesKey).(PropagatedValues)
+	if !ok {
+		return nil
+	}
+
+	payload, err := converter.GetDefaultDataConverter().ToPayload(vals)
+	if err != nil {
+		return err
+	}
+	writer.Set(propagationKey, payload)
+	return nil
+}
+
+// InjectFromWorkflow injects values from context into headers for propagation
+func (s *propagator) InjectFromWorkflow(ctx workflow.Context, writer workflow.HeaderWriter) error {
+	vals, ok := ctx.Value(PropagatedValuesKey).(PropagatedValues)
+	if !ok {
+		return nil
+	}
+
+	payload, err := converter.GetDefaultDataConverter().ToPayload(vals)
+	if err != nil {
+		return err
+	}
+	writer.Set(propagationKey, payload)
+	return nil
+}
+
+// Extract extracts values from headers and puts them into context.
+//
+// The header is missing when a workflow is started, signaled or queried from the UI/CLI,
+// the DataConverter then falls back to its configured KeyID.
+func (s *propagator) Extract(ctx context.Context, reader workflow.HeaderReader) (context.Context, error) {
+	value, ok := reader.Get(propagationKey)
+	if !ok {
+		return ctx, nil
+	}
+
+	var data PropagatedValues
+	if err := converter.GetDefaultDataConverter().FromPayload(value, &data); err != nil {
+		return ctx, fmt.Errorf("failed to extract value from header: %w", err)
+	}
+
+	return context.WithValue(ctx, PropagatedValuesKey, data), nil
+}
+
+// ExtractToWorkflow extracts values from headers and puts them into context
+func (s *propagator) ExtractToWorkflow(ctx workflow.Context, reader workflow.HeaderReader) (workflow.Context, error) {
+	value, ok := reader.Get(propagationKey)
+	if !ok {
+		return ctx, nil
+	}
+
+	var data PropagatedValues
+	if err := converter.GetDefaultDataConverter().FromPayload(value, &data); err != nil {
+		return ctx, fmt.Errorf("failed to extract value from header: %w", err)
+	}
+
+	return workflow.WithValue(ctx, PropagatedValuesKey, data), nil
+}
@@ -0,0 +1,137 @@
+package codec
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+func Test_TenantKeys(t *testing.T) {
+	keyring, err := NewKeyring("default", map[string][]byte{
+		"default":    testKey1,
+		"tenant-key": testKey2,
+	})
+	require.NoError(t, err)
+	dc := NewEncryptionDataConverter(converter.GetDefaultDataConverter(), DataConverterOptions{
+		KeyProvider: keyring,
+		TenantKeyID: func(tenantID string) string { return tenantID + "-key" },
+	})
+
+	ctx := context.WithValue(context.Background(), PropagatedValuesKey, PropagatedValues{TenantID: "tenant"})
+	p, err := dc.WithContext(ctx).ToPayload("for tenant")
+	require.NoError(t, err)
+	require.Equal(t, "tenant-key", string(p.GetMetadata()[MetadataEncryptionKeyID]))
+
+	// decoding only needs the key ID stored in the payload
+	var result string
+	require.NoError(t, dc.FromPayload(p, &result))
+	require.Equal(t, "for tenant", result)
+
+	p, err = dc.WithContext(context.Background()).ToPayload("no tenant")
+	require.NoError(t, err)
+	require.Equal(t, "default", string(p.GetMetadata()[MetadataEncryptionKeyID]))
+}
+
+func Test_TenantKeysInWorkflow(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+	dc := NewEncryptionDataConverter(converter.GetDefaultDataConverter(), DataConverterOptions{})
+	env.SetDataConverter(dc)
+	env.SetContextPropagators([]workflow.ContextPropagator{NewContextPropagator()})
+
+	header, err := converter.GetDefaultDataConverter().ToPayload(PropagatedValues{TenantID: "tenant-1"})
+	require.NoError(t, err)
+	env.SetHeader(&commonpb.Header{Fields: map[string]*commonpb.Payload{propagationKey: header}})
+
+	env.ExecuteWorkflow(func(ctx workflow.Context) (string, error) {
+		p, err := dc.WithWorkflowContext(ctx).ToPayload("x")
+		if err != nil {
+			return "", err
+		}
+		return string(p.GetMetadata()[MetadataEncryptionKeyID]), nil
+	})
+
+	require.NoError(t, env.GetWorkflowError())
+	var keyID string
+	require.NoError(t, env.GetWorkflowResult(&keyID))
+	require.Equal(t, "tenant-1", keyID)
+}
+
+func Test_EncryptedContextPropagator(t *testing.T) {
+	p := NewEncryptedContextPropagator(NewContextPropagator(), &Codec{})
+	ctx := context.WithValue(context.Background(), PropagatedValuesKey, PropagatedValues{TenantID: "tenant-1"})
+
+	header := &commonpb.Header{Fields: map[string]*commonpb.Payload{}}
+	require.NoError(t, p.Inject(ctx, headerFields(header.Fields)))
+	encrypted := header.Fields[propagationKey]
+	require.Equal(t, MetadataEncodingEncrypted, string(encrypted.GetMetadata()[converter.MetadataEncoding]))
+	require.NotContains(t, string(encrypted.GetData()), "tenant-1")
+
+	ctx, err := p.Extract(context.Background(), headerFields(header.Fields))
+	require.NoError(t, err)
+	require.Equal(t, PropagatedValues{TenantID: "tenant-1"}, ctx.Value(PropagatedValuesKey))
+
+	// headers written before the propagator was wrapped are still read
+	plain, err := converter.GetDefaultDataConverter().ToPayload(PropagatedValues{TenantID: "tenant-2"})
+	require.NoError(t, err)
+	header.Fields[propagationKey] = plain
+	ctx, err = p.Extract(context.Background(), headerFields(header.Fields))
+	require.NoError(t, err)
+	require.Equal(t, PropagatedValues{TenantID: "tenant-2"}, ctx.Value(PropagatedValuesKey))
+
+	// a header that fails to decrypt fails the Extract
+	keyring, err := NewKeyring("other", map[string][]byte{"other": testKey2})
+	require.NoError(t, err)
+	header.Fields[propagationKey] = encrypted
+	_, err = NewEncryptedContextPropagator(NewContextPropagator(), &Codec{KeyProvider: keyring}).
+		Extract(context.Background(), headerFields(header.Fields))
+	require.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func Test_EncryptedContextPropagatorInWorkflow(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+	env.SetContextPropagators([]workflow.ContextPropagator{NewEncryptedContextPropagator(NewContextPropagator(), &Codec{})})
+
+	header, err := converter.GetDefaultDataConverter().ToPayload(PropagatedValues{TenantID: "tenant-1"})
+	require.NoError(t, err)
+	encrypted, err := (&Codec{}).Encode([]*commonpb.Payload{header})
+	require.NoError(t, err)
+	env.SetHeader(&commonpb.Header{Fields: map[string]*commonpb.Payload{propagationKey: encrypted[0]}})
+
+	env.ExecuteWorkflow(func(ctx workflow.Context) (string, error) {
+		vals, _ := ctx.Value(PropagatedValuesKey).(PropagatedValues)
+		return vals.TenantID, nil
+	})
+
+	require.NoError(t, env.GetWorkflowError())
+	var tenantID string
+	require.NoError(t, env.GetWorkflowResult(&tenantID))
+	require.Equal(t, "tenant-1", tenantID)
+}
+
+// headerFields is a workflow.HeaderReader and workflow.HeaderWriter over Header fields, the SDK's aren't exported
+type headerFields map[string]*commonpb.Payload
+
+func (h headerFields) Set(key string, value *commonpb.Payload) {
+	h[key] = value
+}
+
+func (h headerFields) Get(key string) (*commonpb.Payload, bool) {
+	value, ok := h[key]
+	return value, ok
+}
+
+func (h headerFields) ForEachKey(handler func(string, *commonpb.Payload) error) error {
+	for key, value := range h {
+		if err := handler(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package codec
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/workflow"
+)
+
+// searchTokenSize is the number of bytes of the HMAC-SHA256 kept in a token, 128 bits
+const searchTokenSize = 16
+
+// SearchTokenizer derives deterministic HMAC-SHA256 tokens from sensitive values, so encrypted workflows can be
+// found with ListWorkflow without the plaintext ever reaching visibility.
+//
+// Tokens are keyed by the search attribute name too, the same value has different tokens in different attributes.
+// Use a key dedicated to search tokens, tokens only match when created with the same key.
+type SearchTokenizer struct {
+	key []byte
+	// Normalize is applied to values before tokenizing, e.g. strings.ToLower for case-insensitive matches.
+	Normalize func(value string) string
+}
+
+// NewSearchTokenizer fetches the tokenizer's key once from provider.
+// Create it outside the workflow and share it, so workflow code doesn't call the KeyProvider.
+func NewSearchTokenizer(provider KeyProvider, keyID string) (*SearchTokenizer, error) {
+	key, err := provider.GetKey(keyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get search token key %q: %w", keyID, err)
+	}
+
+	return &SearchTokenizer{key: key}, nil
+}
+
+// Token returns the hex encoded token of value for the search attribute
+func (t *SearchTokenizer) Token(attribute, value string) string {
+	if t.Normalize != nil {
+		value = t.Normalize(value)
+	}
+
+	mac := hmac.New(sha256.New, t.key)
+	mac.Write([]byte(attribute))
+	mac.Write([]byte{0}) // separates the attribute from the value
+	mac.Write([]byte(value))
+
+	return hex.EncodeToString(mac.Sum(nil)[:searchTokenSize])
+}
+
+// Upsert upserts the tokens of values, keyed by search attribute name, as keyword search attributes.
+// The search attributes must be registered as Keyword, e.g.
+//
+//	temporal operator search-attribute create --name CustomerIDToken --type Keyword
+func (t *SearchTokenizer) Upsert(ctx workflow.Context, values map[string]string) error {
+	// sorted so the upsert command is the same on replay
+	attributes := make([]string, 0, len(values))
+	for attribute := range values {
+		attributes = append(attributes, attribute)
+	}
+	sort.Strings(attributes)
+
+	updates := make([]temporal.SearchAttributeUpdate, 0, len(values))
+	for _, attribute := range attributes {
+		updates = append(updates, temporal.NewSearchAttributeKeyKeyword(attribute).ValueSet(t.Token(attribute, values[attribute])))
+	}
+
+	return workflow.UpsertTypedSearchAttributes(ctx, updates...)
+}
+
+// Query returns a ListWorkflow query matching the workflows whose attribute was upserted with value
+//
+//	CustomerIDToken = '3f1c…'
+func (t *SearchTokenizer) Query(attribute, value string) string {
+	// tokens are hex, so they never need escaping
+	return fmt.Sprintf("%s = '%s'", attribute, t.Token(attribute, value))
+}
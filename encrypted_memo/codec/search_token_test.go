@@ -0,0 +1,58 @@
+package codec
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.temporal.io/sdk/temporal"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+func Test_SearchTokens(t *testing.T) {
+	keyring, err := NewKeyring("search", map[string][]byte{"search": testKey1, "other": testKey2})
+	require.NoError(t, err)
+
+	tokenizer, err := NewSearchTokenizer(keyring, "search")
+	require.NoError(t, err)
+
+	token := tokenizer.Token("CustomerIDToken", "customer-42")
+	require.Len(t, token, 32)
+	require.NotContains(t, token, "customer-42")
+	require.Equal(t, token, tokenizer.Token("CustomerIDToken", "customer-42"), "tokens are deterministic")
+	require.NotEqual(t, token, tokenizer.Token("CustomerIDToken", "customer-43"))
+	require.NotEqual(t, token, tokenizer.Token("EmailToken", "customer-42"), "tokens are keyed by attribute")
+
+	other, err := NewSearchTokenizer(keyring, "other")
+	require.NoError(t, err)
+	require.NotEqual(t, token, other.Token("CustomerIDToken", "customer-42"))
+
+	require.Equal(t, "CustomerIDToken = '"+token+"'", tokenizer.Query("CustomerIDToken", "customer-42"))
+
+	tokenizer.Normalize = strings.ToLower
+	require.Equal(t, tokenizer.Token("EmailToken", "a@example.com"), tokenizer.Token("EmailToken", "A@Example.com"))
+
+	_, err = NewSearchTokenizer(keyring, "missing")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func Test_SearchTokensUpsert(t *testing.T) {
+	tokenizer, err := NewSearchTokenizer(testKeyProvider{}, "search")
+	require.NoError(t, err)
+
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+	env.ExecuteWorkflow(func(ctx workflow.Context) (string, error) {
+		if err := tokenizer.Upsert(ctx, map[string]string{"CustomerIDToken": "customer-42"}); err != nil {
+			return "", err
+		}
+		token, _ := workflow.GetTypedSearchAttributes(ctx).GetKeyword(temporal.NewSearchAttributeKeyKeyword("CustomerIDToken"))
+		return token, nil
+	})
+
+	require.NoError(t, env.GetWorkflowError())
+	var token string
+	require.NoError(t, env.GetWorkflowResult(&token))
+	require.Equal(t, tokenizer.Token("CustomerIDToken", "customer-42"), token)
+}
@@ -0,0 +1,62 @@
+package codec
+
+import (
+	"errors"
+	"failsafe"
+	"fmt"
+	"time"
+
+	commonpb "go.temporal.io/api/common/v1"
+)
+
+// MetadataEncryptionRedacted marks the placeholder Decode returns for payloads whose key was shredded
+const MetadataEncryptionRedacted = "encryption-redacted"
+
+// ErrKeyShredded is returned by a KeyProvider for a key that was destroyed with KeyShredder.ShredKey
+var ErrKeyShredded = errors.New("encryption key was shredded")
+
+// KeyShredder is implemented by key providers that can destroy a key for good, e.g. for a right-to-be-forgotten
+// request. A tombstone is kept so payloads encrypted with the key are reported as shredded rather than broken.
+type KeyShredder interface {
+	ShredKey(keyID string) error
+}
+
+// KeyShreddedError is ErrKeyShredded with the key ID and when it was shredded
+type KeyShreddedError struct {
+	KeyID      string
+	ShreddedAt time.Time
+}
+
+func (e *KeyShreddedError) Error() string {
+	return fmt.Sprintf("%v: %s at %s", ErrKeyShredded, e.KeyID, e.ShreddedAt.Format(time.RFC3339))
+}
+
+func (e *KeyShreddedError) Unwrap() error {
+	return ErrKeyShredded
+}
+
+// RedactedPayload is the json body of the placeholder Codec.Decode returns instead of a KeyShreddedError,
+// so the UI, CLI and replay tooling keep working on the rest of the history.
+type RedactedPayload struct {
+	Redacted   string    `json:"redacted"`
+	KeyID      string    `json:"keyId"`
+	ShreddedAt time.Time `json:"shreddedAt"`
+}
+
+// payload is the RedactedPayload of a payload encrypted with the shredded keyID
+func (e *KeyShreddedError) payload(keyID string) *commonpb.Payload {
+	payload, _ := failsafe.Placeholder(RedactedPayload{ // can't fail for strings and a time
+		Redacted:   "key-shredded",
+		KeyID:      keyID,
+		ShreddedAt: e.ShreddedAt,
+	}, MetadataEncryptionRedacted, "key-shredded")
+
+	return payload
+}
+
+// zero overwrites key material that's being shredded
+func zero(key []byte) {
+	for i := range key {
+		key[i] = 0
+	}
+}
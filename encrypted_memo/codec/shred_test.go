@@ -0,0 +1,153 @@
+package codec
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+)
+
+// requireShredded checks the payloads encrypted with keyID decode to the placeholder, and the others still decode
+func requireShredded(t *testing.T, dc converter.DataConverter, shredded, kept *commonpb.Payload, keyID string) {
+	t.Helper()
+
+	var redacted RedactedPayload
+	require.NoError(t, dc.FromPayload(shredded, &redacted))
+	require.Equal(t, "key-shredded", redacted.Redacted)
+	require.Equal(t, keyID, redacted.KeyID)
+	require.False(t, redacted.ShreddedAt.IsZero())
+
+	// typed values fail to decode, but not with a key error
+	var s string
+	err := dc.FromPayload(shredded, &s)
+	require.Error(t, err)
+	require.False(t, errors.Is(err, ErrKeyShredded))
+
+	require.NoError(t, dc.FromPayload(kept, &s))
+	require.Equal(t, "kept", s)
+}
+
+func Test_KeyringShred(t *testing.T) {
+	keyring, err := NewKeyring("default", map[string][]byte{
+		"default":  append([]byte(nil), testKey1...),
+		"tenant-1": append([]byte(nil), testKey2...),
+	})
+	require.NoError(t, err)
+	dc := NewEncryptionDataConverter(converter.GetDefaultDataConverter(), DataConverterOptions{KeyProvider: keyring})
+	tenantDC := NewEncryptionDataConverter(converter.GetDefaultDataConverter(), DataConverterOptions{
+		KeyProvider: keyring,
+		KeyID:       "tenant-1",
+	})
+
+	forgotten, err := tenantDC.ToPayload("forget me")
+	require.NoError(t, err)
+	kept, err := dc.ToPayload("kept")
+	require.NoError(t, err)
+
+	require.ErrorContains(t, keyring.ShredKey("default"), "active key")
+	require.ErrorIs(t, keyring.ShredKey("unknown"), ErrKeyNotFound)
+	require.NoError(t, keyring.ShredKey("tenant-1"))
+	require.NoError(t, keyring.ShredKey("tenant-1"), "shredding is idempotent")
+
+	_, err = keyring.GetKey("tenant-1")
+	var shreddedErr *KeyShreddedError
+	require.True(t, errors.As(err, &shreddedErr))
+	require.Equal(t, "tenant-1", shreddedErr.KeyID)
+	require.NotContains(t, keyring.KeyIDs(), "tenant-1")
+
+	requireShredded(t, dc, forgotten, kept, "tenant-1")
+}
+
+func Test_FileKeyProviderShred(t *testing.T) {
+	for _, format := range []string{"json", "pem"} {
+		t.Run(format, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "keyring."+format)
+			if format == "json" {
+				b, err := json.Marshal(keyringFile{Active: "default", Keys: map[string][]byte{"default": testKey1, "tenant-1": testKey2}})
+				require.NoError(t, err)
+				require.NoError(t, os.WriteFile(path, b, 0o600))
+			} else {
+				b := append(EncodeKeyPEM("tenant-1", testKey2), EncodeKeyPEM("default", testKey1)...)
+				require.NoError(t, os.WriteFile(path, b, 0o600))
+			}
+
+			p, err := NewFileKeyProvider(path)
+			require.NoError(t, err)
+			dc := NewEncryptionDataConverter(converter.GetDefaultDataConverter(), DataConverterOptions{KeyProvider: p, KeyID: "default"})
+			forgotten, err := NewEncryptionDataConverter(converter.GetDefaultDataConverter(), DataConverterOptions{
+				KeyProvider: p,
+				KeyID:       "tenant-1",
+			}).ToPayload("forget me")
+			require.NoError(t, err)
+			kept, err := dc.ToPayload("kept")
+			require.NoError(t, err)
+
+			// a keyring that can't be saved keeps the key
+			p.path = filepath.Join(t.TempDir(), "missing", "keyring."+format)
+			require.Error(t, p.ShredKey("tenant-1"))
+			held, err := p.GetKey("tenant-1")
+			require.NoError(t, err)
+			p.path = path
+
+			require.NoError(t, p.ShredKey("tenant-1"))
+			requireShredded(t, dc, forgotten, kept, "tenant-1")
+			require.Equal(t, testKey2, held, "keys returned before are copies")
+
+			// the tombstone is persisted and the key material is gone from the file
+			b, err := os.ReadFile(path)
+			require.NoError(t, err)
+			require.NotContains(t, string(b), string(testKey2))
+
+			reloaded, err := NewFileKeyProvider(path)
+			require.NoError(t, err)
+			_, err = reloaded.GetKey("tenant-1")
+			require.ErrorIs(t, err, ErrKeyShredded)
+			key, err := reloaded.GetKey("default")
+			require.NoError(t, err)
+			require.Equal(t, testKey1, key)
+		})
+	}
+}
+
+func Test_HTTPKeyProviderShred(t *testing.T) {
+	kms := newTestKMS(t, "s3cr3t", map[string][]byte{"key-1": testKey1, "tenant-1": testKey2})
+	provider := NewHTTPKeyProvider(kms.URL)
+	provider.Token = "s3cr3t"
+
+	for _, envelope := range []bool{false, true} {
+		tenantDC := NewEncryptionDataConverter(converter.GetDefaultDataConverter(), DataConverterOptions{
+			KeyProvider:        provider,
+			KeyID:              "tenant-1",
+			EnvelopeEncryption: envelope,
+		})
+		forgotten, err := tenantDC.ToPayload("forget me")
+		require.NoError(t, err)
+
+		dc := NewEncryptionDataConverter(converter.GetDefaultDataConverter(), DataConverterOptions{
+			KeyProvider:        provider,
+			KeyID:              "key-1",
+			EnvelopeEncryption: envelope,
+		})
+		kept, err := dc.ToPayload("kept")
+		require.NoError(t, err)
+
+		require.NoError(t, provider.ShredKey("tenant-1"))
+		requireShredded(t, dc, forgotten, kept, "tenant-1")
+
+		_, err = provider.GetKey("tenant-1")
+		var shreddedErr *KeyShreddedError
+		require.True(t, errors.As(err, &shreddedErr))
+		require.Equal(t, "tenant-1", shreddedErr.KeyID)
+
+		// restore the key for the envelope run
+		kms.mu.Lock()
+		delete(kms.shredded, "tenant-1")
+		kms.keys["tenant-1"] = testKey2
+		kms.mu.Unlock()
+	}
+}
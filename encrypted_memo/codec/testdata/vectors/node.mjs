@@ -0,0 +1,97 @@
+// Writes node.json, test vectors encrypted without the Go code: the layout of the TypeScript encryption sample,
+// AES-256-GCM through WebCrypto with a 12 byte random IV prepended and no additional data, see FORMAT.md.
+// It first checks the AES-256-GCM vectors of go.json decrypt the same way.
+// The Payload protobuf is encoded by hand so it needs nothing but Node.js 18+
+//
+//	node codec/testdata/vectors/node.mjs
+import { webcrypto } from 'node:crypto';
+import { readFileSync, writeFileSync } from 'node:fs';
+
+const utf8 = (s) => new TextEncoder().encode(s);
+
+function varint(n) {
+  const out = [];
+  while (n > 0x7f) {
+    out.push((n & 0x7f) | 0x80);
+    n >>>= 7;
+  }
+  out.push(n);
+  return out;
+}
+
+// field encodes a length-delimited protobuf field
+const field = (num, bytes) => [...varint((num << 3) | 2), ...varint(bytes.length), ...bytes];
+
+// encodePayload encodes temporal.api.common.v1.Payload: map<string, bytes> metadata = 1; bytes data = 2;
+function encodePayload({ metadata, data }) {
+  const out = [];
+  for (const [k, v] of Object.entries(metadata)) {
+    out.push(...field(1, [...field(1, utf8(k)), ...field(2, v)]));
+  }
+  out.push(...field(2, data));
+  return Uint8Array.from(out);
+}
+
+const b64 = (bytes) => Buffer.from(bytes).toString('base64');
+const protojson = ({ metadata, data }) => ({
+  metadata: Object.fromEntries(Object.entries(metadata).map(([k, v]) => [k, b64(v)])),
+  data: b64(data),
+});
+
+async function encrypt(name, keyId, keyBytes, metadata, plain) {
+  const key = await webcrypto.subtle.importKey('raw', keyBytes, 'AES-GCM', false, ['encrypt']);
+  const iv = webcrypto.getRandomValues(new Uint8Array(12));
+  const sealed = new Uint8Array(await webcrypto.subtle.encrypt({ name: 'AES-GCM', iv }, key, encodePayload(plain)));
+  const encrypted = { metadata, data: Uint8Array.from([...iv, ...sealed]) };
+  return { name, keyId, key: b64(keyBytes), encrypted: protojson(encrypted), decoded: protojson(plain) };
+}
+
+// decrypt opens an AES-256-GCM vector, the encryption-binding metadata is the additional data
+async function decrypt({ name, key, encrypted, decoded }) {
+  const k = await webcrypto.subtle.importKey('raw', Buffer.from(key, 'base64'), 'AES-GCM', false, ['decrypt']);
+  const data = Buffer.from(encrypted.data, 'base64');
+  const binding = encrypted.metadata['encryption-binding'];
+  const params = { name: 'AES-GCM', iv: data.subarray(0, 12) };
+  if (binding) {
+    params.additionalData = Buffer.from(binding, 'base64');
+  }
+  const plain = new Uint8Array(await webcrypto.subtle.decrypt(params, k, data.subarray(12)));
+  const expected = encodePayload({
+    metadata: Object.fromEntries(Object.entries(decoded.metadata).map(([mk, v]) => [mk, Buffer.from(v, 'base64')])),
+    data: Buffer.from(decoded.data, 'base64'),
+  });
+  if (b64(plain) !== b64(expected)) {
+    throw new Error(`go.json ${name}: decrypted payload doesn't match`);
+  }
+}
+
+const goVectors = JSON.parse(readFileSync(new URL('go.json', import.meta.url)));
+for (const v of goVectors.vectors) {
+  const cipher = v.encrypted.metadata['encryption-cipher'];
+  const aesGCM = !cipher || Buffer.from(cipher, 'base64').toString() === 'AES-256-GCM';
+  if (aesGCM && !v.encrypted.metadata['encryption-data-key']) {
+    await decrypt(v);
+  }
+}
+
+const key = utf8('test-key-test-key-test-key-test!');
+const plain = { metadata: { encoding: utf8('json/plain') }, data: utf8('{"name":"My Secret Friend"}') };
+
+const vectors = {
+  source: 'codec/testdata/vectors/node.mjs, Node.js ' + process.version,
+  vectors: [
+    // as written by the TypeScript sample, without a format tag
+    await encrypt('typescript-sample', 'test-key-id', key, {
+      encoding: utf8('binary/encrypted'),
+      'encryption-key-id': utf8('test-key-id'),
+    }, plain),
+    await encrypt('format-v1', 'test-key-id', key, {
+      encoding: utf8('binary/encrypted'),
+      'encryption-format': utf8('1'),
+      'encryption-key-id': utf8('test-key-id'),
+      'encryption-cipher': utf8('AES-256-GCM'),
+    }, plain),
+  ],
+};
+
+writeFileSync(new URL('node.json', import.meta.url), JSON.stringify(vectors, null, 2) + '\n');
@@ -0,0 +1,130 @@
+package codec
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	commonpb "go.temporal.io/api/common/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+
+	"go.temporal.io/sdk/converter"
+)
+
+var updateVectors = flag.Bool("update-vectors", false, "regenerate testdata/vectors/go.json")
+
+// vectorFile is the layout of the golden test vectors in testdata/vectors, see FORMAT.md
+type vectorFile struct {
+	Source  string   `json:"source"`
+	Vectors []vector `json:"vectors"`
+}
+
+type vector struct {
+	Name      string          `json:"name"`
+	KeyID     string          `json:"keyId"`
+	Key       []byte          `json:"key"`
+	Encrypted json.RawMessage `json:"encrypted"` // protojson Payload
+	Decoded   json.RawMessage `json:"decoded"`   // protojson Payload
+}
+
+// Test_Vectors decodes the vectors of every file in testdata/vectors, including those written by other SDKs
+func Test_Vectors(t *testing.T) {
+	if *updateVectors {
+		writeGoVectors(t)
+	}
+
+	files, err := filepath.Glob(filepath.Join("testdata", "vectors", "*.json"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	for _, file := range files {
+		b, err := os.ReadFile(file)
+		require.NoError(t, err)
+		var vectors vectorFile
+		require.NoError(t, json.Unmarshal(b, &vectors))
+
+		for _, v := range vectors.Vectors {
+			t.Run(filepath.Base(file)+"/"+v.Name, func(t *testing.T) {
+				encrypted, decoded := &commonpb.Payload{}, &commonpb.Payload{}
+				require.NoError(t, protojson.Unmarshal(v.Encrypted, encrypted))
+				require.NoError(t, protojson.Unmarshal(v.Decoded, decoded))
+
+				keyring, err := NewKeyring(v.KeyID, map[string][]byte{v.KeyID: v.Key})
+				require.NoError(t, err)
+				result, err := (&Codec{KeyProvider: keyring}).Decode([]*commonpb.Payload{encrypted})
+				require.NoError(t, err)
+				require.True(t, proto.Equal(decoded, result[0]), "got %v", result[0])
+			})
+		}
+	}
+}
+
+func Test_UnsupportedFormat(t *testing.T) {
+	encoded, err := (&Codec{}).Encode([]*commonpb.Payload{{Data: []byte("v2")}})
+	require.NoError(t, err)
+	require.Equal(t, EncryptionFormatV1, string(encoded[0].GetMetadata()[MetadataEncryptionFormat]))
+
+	encoded[0].Metadata[MetadataEncryptionFormat] = []byte("2")
+	_, err = (&Codec{}).Decode(encoded)
+	require.ErrorIs(t, err, ErrUnsupportedFormat)
+}
+
+// writeGoVectors regenerates testdata/vectors/go.json with
+//
+//	go test ./codec -run Test_Vectors -update-vectors
+func writeGoVectors(t *testing.T) {
+	plain, err := converter.GetDefaultDataConverter().ToPayload(map[string]string{"name": "My Secret Friend"})
+	require.NoError(t, err)
+
+	encode := func(name string, c *Codec, key []byte) vector {
+		keyring, err := NewKeyring("key-1", map[string][]byte{"key-1": key})
+		require.NoError(t, err)
+		c.KeyProvider = keyring
+		encoded, err := c.Encode([]*commonpb.Payload{plain})
+		require.NoError(t, err)
+
+		return vector{
+			Name:      name,
+			KeyID:     "key-1",
+			Key:       key,
+			Encrypted: marshalVectorPayload(t, encoded[0]),
+			Decoded:   marshalVectorPayload(t, plain),
+		}
+	}
+
+	untagged := encode("untagged", &Codec{}, testKey1)
+	legacy := &commonpb.Payload{}
+	require.NoError(t, protojson.Unmarshal(untagged.Encrypted, legacy))
+	delete(legacy.Metadata, MetadataEncryptionFormat)
+	delete(legacy.Metadata, MetadataEncryptionCipher)
+	untagged.Encrypted = marshalVectorPayload(t, legacy)
+
+	binding := &Binding{Namespace: "default", WorkflowID: "encryption_workflowID", Role: BindingRoleWorkflow}
+
+	vectors := vectorFile{
+		Source: "encrypted_memo/codec, go test ./codec -run Test_Vectors -update-vectors",
+		Vectors: []vector{
+			encode("aes-256-gcm", &Codec{}, testKey1),
+			encode("chacha20-poly1305", &Codec{Cipher: CipherChaCha20Poly1305}, testKey1),
+			encode("xchacha20-poly1305", &Codec{Cipher: CipherXChaCha20Poly1305}, testKey1),
+			encode("envelope", &Codec{Envelope: true}, testKey1),
+			encode("bound", &Codec{BindToWorkflow: true, binding: binding}, testKey1),
+			untagged,
+		},
+	}
+
+	b, err := json.MarshalIndent(vectors, "", "  ")
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Join("testdata", "vectors"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join("testdata", "vectors", "go.json"), append(b, '\n'), 0o644))
+}
+
+func marshalVectorPayload(t *testing.T, p *commonpb.Payload) json.RawMessage {
+	b, err := protojson.Marshal(p)
+	require.NoError(t, err)
+	return b
+}
@@ -0,0 +1,59 @@
+// Package keyflags registers the flags selecting the codec.KeyProvider, so the worker, starter and codec server
+// resolve keys the same way.
+package keyflags
+
+import (
+	"errors"
+	"flag"
+	"os"
+
+	"encrypted_memo/codec"
+)
+
+var keyringFlag string
+var kmsURLFlag string
+var keyEnvFlag bool
+var keyIDFlag string
+
+func init() {
+	flag.StringVar(&keyringFlag, "keyring", "", "JSON or PEM keyring file to load keys from")
+	flag.StringVar(&kmsURLFlag, "kms", "", "URL of an HTTP KMS to fetch keys from, KMS_TOKEN is sent as a bearer token. Keys are cached")
+	flag.BoolVar(&keyEnvFlag, "key-env", false, "Read keys from TEMPORAL_ENCRYPTION_KEY_<KEY_ID> environment variables")
+	flag.StringVar(&keyIDFlag, "key-id", "", "Key ID to encrypt with outside of a tenant, e.g. the propagated headers. Defaults to the active key of -keyring, required with -kms and -key-env")
+}
+
+// KeyProvider returns the KeyProvider picked by the flags, nil uses the codec's hard coded test key.
+// Keys of the -kms are cached, workflow code like the encrypted context propagator resolves keys and shouldn't wait
+// on the KMS.
+func KeyProvider() (codec.KeyProvider, error) {
+	switch {
+	case keyringFlag != "":
+		return codec.NewFileKeyProvider(keyringFlag)
+	case kmsURLFlag != "":
+		p := codec.NewHTTPKeyProvider(kmsURLFlag)
+		p.Token = os.Getenv("KMS_TOKEN")
+		return codec.NewCachingKeyProvider(p, codec.CachingKeyProviderOptions{}), nil
+	case keyEnvFlag:
+		return codec.NewEnvKeyProvider(), nil
+	default:
+		return nil, nil
+	}
+}
+
+// KeyID returns the key ID to encrypt with outside of a tenant, like the headers of the encrypted context propagator.
+// It's -key-id, or the active key of provider. The KeyProviders of -kms and -key-env have no active key, so they
+// require -key-id rather than encrypt with an empty key ID.
+func KeyID(provider codec.KeyProvider) (string, error) {
+	if keyIDFlag != "" {
+		return keyIDFlag, nil
+	}
+	// the hard coded test key
+	if provider == nil {
+		return "", nil
+	}
+	if p, ok := provider.(codec.ActiveKeyProvider); ok && p.ActiveKeyID() != "" {
+		return p.ActiveKeyID(), nil
+	}
+
+	return "", errors.New("-key-id is required, the key provider has no active key")
+}
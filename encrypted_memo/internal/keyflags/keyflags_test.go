@@ -0,0 +1,80 @@
+package keyflags
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"encrypted_memo/codec"
+
+	"github.com/stretchr/testify/require"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+)
+
+// headerFields is a workflow.HeaderReader and workflow.HeaderWriter over Header fields
+type headerFields map[string]*commonpb.Payload
+
+func (h headerFields) Set(key string, value *commonpb.Payload) {
+	h[key] = value
+}
+
+func (h headerFields) Get(key string) (*commonpb.Payload, bool) {
+	value, ok := h[key]
+	return value, ok
+}
+
+func (h headerFields) ForEachKey(handler func(string, *commonpb.Payload) error) error {
+	for key, value := range h {
+		if err := handler(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func Test_KeyIDWithKMS(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	kms := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keyID := strings.TrimPrefix(r.URL.Path, "/keys/")
+		if keyID != "header-key" {
+			http.NotFound(w, r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(codec.KMSKeyResponse{KeyID: keyID, Key: key})
+	}))
+	defer kms.Close()
+
+	kmsURLFlag = kms.URL
+	t.Cleanup(func() { kmsURLFlag, keyIDFlag = "", "" })
+
+	keyProvider, err := KeyProvider()
+	require.NoError(t, err)
+	_, err = KeyID(keyProvider)
+	require.Error(t, err, "the KMS has no active key")
+
+	keyIDFlag = "header-key"
+	keyID, err := KeyID(keyProvider)
+	require.NoError(t, err)
+	require.Equal(t, "header-key", keyID)
+
+	// the worker and starter encrypt the propagated headers with it
+	options := codec.DataConverterOptions{KeyID: keyID, KeyProvider: keyProvider, Compress: true}
+	propagator := codec.NewEncryptedContextPropagator(codec.NewContextPropagator(), codec.NewCodec(options))
+	headers := headerFields{}
+	ctx := context.WithValue(context.Background(), codec.PropagatedValuesKey, codec.PropagatedValues{TenantID: "tenant-1"})
+	require.NoError(t, propagator.Inject(ctx, headers))
+	require.NotEmpty(t, headers)
+	for _, p := range headers {
+		require.Equal(t, codec.MetadataEncodingEncrypted, string(p.GetMetadata()[converter.MetadataEncoding]))
+		require.Equal(t, "header-key", string(p.GetMetadata()[codec.MetadataEncryptionKeyID]))
+	}
+
+	ctx, err = propagator.Extract(context.Background(), headers)
+	require.NoError(t, err)
+	require.Equal(t, codec.PropagatedValues{TenantID: "tenant-1"}, ctx.Value(codec.PropagatedValuesKey))
+}
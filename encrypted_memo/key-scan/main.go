@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"text/tabwriter"
+
+	"encrypted_memo/codec"
+
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/api/enums/v1"
+	"go.temporal.io/api/proxy"
+	"go.temporal.io/api/workflowservice/v1"
+	"go.temporal.io/sdk/client"
+)
+
+var query string
+var retiredKeyID string
+var concurrency int
+
+func init() {
+	flag.StringVar(&query, "query", "", "Visibility query selecting the workflows to scan, defaults to all open and retained workflows")
+	flag.StringVar(&retiredKeyID, "retired", "", "Exit non-zero if this key ID is still used by any workflow history")
+	flag.IntVar(&concurrency, "concurrency", 4, "Number of histories to fetch concurrently")
+}
+
+// keyUsage is how often a key ID appears across the scanned histories
+type keyUsage struct {
+	payloads        int
+	openWorkflows   int
+	closedWorkflows int
+}
+
+// This reports which encryption-key-id values still appear in open or retained workflow histories.
+// A retired key can only be destroyed once no history references it anymore.
+//
+// The key IDs of the fields of json/field-encrypted payloads are counted too. Payloads nested inside a
+// binary/encrypted payload, e.g. field encrypted payloads that are also encrypted as a whole, can't be read
+// without the key, only the key ID of the outer payload is counted for them.
+func main() {
+	flag.Parse()
+	os.Exit(run())
+}
+
+// run returns the exit code, so the deferred cleanup runs before exiting
+func run() int {
+	ctx := context.Background()
+
+	// No DataConverter here, we only need the payload metadata
+	c, err := client.Dial(client.Options{})
+	if err != nil {
+		log.Println("Unable to create client", err)
+		return 1
+	}
+	defer c.Close()
+
+	var mu sync.Mutex
+	usage := map[string]*keyUsage{}
+	scanned := 0
+	failed := 0
+
+	jobs := make(chan *commonpb.WorkflowExecution)
+	open := map[string]bool{}
+	wg := &sync.WaitGroup{}
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for exec := range jobs {
+				counts, err := scanHistory(ctx, c, exec)
+				if err != nil {
+					log.Printf("failed scanning %s/%s: %v\n", exec.GetWorkflowId(), exec.GetRunId(), err)
+					mu.Lock()
+					failed++
+					mu.Unlock()
+					continue
+				}
+
+				mu.Lock()
+				scanned++
+				for keyID, n := range counts {
+					u, ok := usage[keyID]
+					if !ok {
+						u = &keyUsage{}
+						usage[keyID] = u
+					}
+					u.payloads += n
+					if open[exec.GetRunId()] {
+						u.openWorkflows++
+					} else {
+						u.closedWorkflows++
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	var listErr error
+	var nextPageToken []byte
+	for {
+		resp, err := c.ListWorkflow(ctx, &workflowservice.ListWorkflowExecutionsRequest{
+			Query:         query,
+			NextPageToken: nextPageToken,
+		})
+		if err != nil {
+			listErr = err
+			break
+		}
+
+		for _, info := range resp.GetExecutions() {
+			mu.Lock()
+			open[info.GetExecution().GetRunId()] = info.GetStatus() == enums.WORKFLOW_EXECUTION_STATUS_RUNNING
+			mu.Unlock()
+			jobs <- info.GetExecution()
+		}
+
+		nextPageToken = resp.GetNextPageToken()
+		if len(nextPageToken) == 0 {
+			break
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	if listErr != nil {
+		log.Println("Unable to list workflows", listErr)
+		return 1
+	}
+
+	keyIDs := make([]string, 0, len(usage))
+	for keyID := range usage {
+		keyIDs = append(keyIDs, keyID)
+	}
+	sort.Strings(keyIDs)
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "KEY ID\tPAYLOADS\tOPEN WORKFLOWS\tCLOSED WORKFLOWS")
+	for _, keyID := range keyIDs {
+		u := usage[keyID]
+		_, _ = fmt.Fprintf(tw, "%q\t%d\t%d\t%d\n", keyID, u.payloads, u.openWorkflows, u.closedWorkflows)
+	}
+	_ = tw.Flush()
+	fmt.Printf("\nscanned %d workflow histories, %d failed\n", scanned, failed)
+
+	if retiredKeyID == "" {
+		return 0
+	}
+	if u, ok := usage[retiredKeyID]; ok {
+		fmt.Printf("key %q is still used by %d workflows, it can not be destroyed yet\n", retiredKeyID, u.openWorkflows+u.closedWorkflows)
+		return 1
+	}
+	// a history that wasn't scanned may still use the key
+	if failed > 0 {
+		fmt.Printf("could not verify key %q is unused, %d workflow histories failed to scan\n", retiredKeyID, failed)
+		return 1
+	}
+
+	return 0
+}
+
+// scanHistory counts the encrypted payloads per key ID in a workflow history
+func scanHistory(ctx context.Context, c client.Client, exec *commonpb.WorkflowExecution) (map[string]int, error) {
+	counts := map[string]int{}
+	iter := c.GetWorkflowHistory(ctx, exec.GetWorkflowId(), exec.GetRunId(), false, enums.HISTORY_EVENT_FILTER_TYPE_ALL_EVENT)
+	for iter.HasNext() {
+		event, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		err = proxy.VisitPayloads(ctx, event, proxy.VisitPayloadsOptions{
+			Visitor: func(_ *proxy.VisitPayloadsContext, payloads []*commonpb.Payload) ([]*commonpb.Payload, error) {
+				for _, p := range payloads {
+					switch string(p.GetMetadata()["encoding"]) {
+					case codec.MetadataEncodingEncrypted:
+						counts[string(p.GetMetadata()[codec.MetadataEncryptionKeyID])]++
+					case codec.MetadataEncodingFieldEncrypted:
+						fields, err := codec.EncryptedFieldPayloads(p)
+						if err != nil {
+							return nil, fmt.Errorf("event %d: %w", event.GetEventId(), err)
+						}
+						for _, f := range fields {
+							counts[string(f.GetMetadata()[codec.MetadataEncryptionKeyID])]++
+						}
+					}
+				}
+				return payloads, nil
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return counts, nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"encrypted_memo/codec"
+)
+
+var keyID string
+var keyringPath string
+var kmsURL string
+
+func init() {
+	flag.StringVar(&keyID, "key", "", "Key ID to shred")
+	flag.StringVar(&keyringPath, "keyring", "", "JSON or PEM keyring file to shred the key from")
+	flag.StringVar(&kmsURL, "kms", "", "URL of an HTTP KMS to shred the key from, KMS_TOKEN is sent as a bearer token")
+}
+
+// This destroys an encryption key for good, e.g. for a right-to-be-forgotten request.
+// Payloads encrypted with it decode to a redacted placeholder from then on.
+// Run ./key-scan first to see which workflows still use the key.
+// Running workers and codec servers keep a shredded keyring key in memory, restart them afterwards.
+func main() {
+	flag.Parse()
+	if keyID == "" {
+		log.Fatalln("-key is required")
+	}
+
+	var shredder codec.KeyShredder
+	switch {
+	case keyringPath != "":
+		p, err := codec.NewFileKeyProvider(keyringPath)
+		if err != nil {
+			log.Fatalln("Unable to load keyring", err)
+		}
+		shredder = p
+	case kmsURL != "":
+		p := codec.NewHTTPKeyProvider(kmsURL)
+		p.Token = os.Getenv("KMS_TOKEN")
+		shredder = p
+	default:
+		log.Fatalln("-keyring or -kms is required")
+	}
+
+	if err := shredder.ShredKey(keyID); err != nil {
+		log.Fatalln("Unable to shred key", err)
+	}
+	log.Println("Shredded key", keyID)
+}
@@ -0,0 +1,121 @@
+// Package memo reads and writes workflow memos through the configured DataConverter,
+// so encrypted memo values are decoded like any other payload.
+package memo
+
+import (
+	"fmt"
+	"sort"
+
+	"encrypted_memo/codec"
+
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/workflow"
+)
+
+// NotFoundError is returned by Get when the memo has no value for Key
+type NotFoundError struct {
+	Key string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("memo key %q not found", e.Key)
+}
+
+// DecodeError is returned when the value of Key can't be decoded by the DataConverter
+type DecodeError struct {
+	Key string
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("failed to decode memo key %q: %v", e.Key, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// NotEncryptedError is returned by Upsert when the DataConverter doesn't encrypt the value of Key
+type NotEncryptedError struct {
+	Key      string
+	Encoding string
+}
+
+func (e *NotEncryptedError) Error() string {
+	return fmt.Sprintf("memo key %q is not encrypted, encoding is %q", e.Key, e.Encoding)
+}
+
+// Get decodes the value of key with dc.
+// For workflows, the memo is workflow.GetInfo(ctx).Memo, for clients it's in the DescribeWorkflowExecution response.
+func Get[T any](dc converter.DataConverter, memo *commonpb.Memo, key string) (T, error) {
+	var value T
+	payload, ok := memo.GetFields()[key]
+	if !ok {
+		return value, &NotFoundError{Key: key}
+	}
+
+	if err := dc.FromPayload(payload, &value); err != nil {
+		return value, &DecodeError{Key: key, Err: err}
+	}
+
+	return value, nil
+}
+
+// GetAll decodes all values of the memo with dc, an empty memo returns an empty map.
+// Keys are decoded in sorted order, so a workflow fails on the same key on every replay.
+func GetAll(dc converter.DataConverter, memo *commonpb.Memo) (map[string]interface{}, error) {
+	keys := make([]string, 0, len(memo.GetFields()))
+	//workflowcheck:ignore Only collects the keys, they're sorted before use
+	for key := range memo.GetFields() {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	values := make(map[string]interface{}, len(keys))
+	for _, key := range keys {
+		value, err := Get[interface{}](dc, memo, key)
+		if err != nil {
+			return nil, err
+		}
+		values[key] = value
+	}
+
+	return values, nil
+}
+
+// Upsert encodes values with dc and upserts them into the memo of the current workflow, a nil value removes the key.
+// The encoded payloads are upserted as is, so they're encrypted with or without TEMPORAL_SDK_FLAG_7=1.
+// It fails without upserting anything when dc doesn't encrypt a value.
+func Upsert(ctx workflow.Context, dc converter.DataConverter, values map[string]interface{}) error {
+	if len(values) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(values))
+	//workflowcheck:ignore Only collects the keys, they're sorted before use
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	encoded := make(map[string]interface{}, len(keys))
+	for _, key := range keys {
+		value := values[key]
+		if value == nil {
+			encoded[key] = nil
+			continue
+		}
+
+		payload, err := dc.ToPayload(value)
+		if err != nil {
+			return fmt.Errorf("failed to encode memo key %q: %w", key, err)
+		}
+		if encoding := string(payload.GetMetadata()[converter.MetadataEncoding]); encoding != codec.MetadataEncodingEncrypted {
+			return &NotEncryptedError{Key: key, Encoding: encoding}
+		}
+		encoded[key] = converter.NewRawValue(payload)
+	}
+
+	return workflow.UpsertMemo(ctx, encoded)
+}
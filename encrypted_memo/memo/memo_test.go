@@ -0,0 +1,106 @@
+package memo
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"encrypted_memo/codec"
+
+	"github.com/stretchr/testify/require"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/testsuite"
+	"go.temporal.io/sdk/workflow"
+)
+
+func Test_Get(t *testing.T) {
+	dc := codec.DefaultEncryptionCodec
+	city, err := dc.ToPayload("seattle")
+	require.NoError(t, err)
+	count, err := dc.ToPayload(2)
+	require.NoError(t, err)
+	// memos upserted without TEMPORAL_SDK_FLAG_7 aren't encrypted
+	plain, err := converter.GetDefaultDataConverter().ToPayload(true)
+	require.NoError(t, err)
+	m := &commonpb.Memo{Fields: map[string]*commonpb.Payload{"city": city, "count": count, "plain": plain}}
+
+	s, err := Get[string](dc, m, "city")
+	require.NoError(t, err)
+	require.Equal(t, "seattle", s)
+
+	n, err := Get[int](dc, m, "count")
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+
+	values, err := GetAll(dc, m)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"city": "seattle", "count": float64(2), "plain": true}, values)
+
+	_, err = Get[string](dc, m, "missing")
+	var notFound *NotFoundError
+	require.True(t, errors.As(err, &notFound))
+	require.Equal(t, "missing", notFound.Key)
+
+	_, err = Get[int](dc, m, "city")
+	var decodeErr *DecodeError
+	require.True(t, errors.As(err, &decodeErr))
+	require.Equal(t, "city", decodeErr.Key)
+
+	values, err = GetAll(dc, nil)
+	require.NoError(t, err)
+	require.Empty(t, values)
+}
+
+func Test_GetAllSortedKeys(t *testing.T) {
+	dc := codec.DefaultEncryptionCodec
+	// neither can be decoded, the first key in sorted order is always the one reported
+	undecodable := &commonpb.Payload{Metadata: map[string][]byte{"encoding": []byte("binary/unknown")}}
+	m := &commonpb.Memo{Fields: map[string]*commonpb.Payload{"b": undecodable, "a": undecodable, "c": undecodable}}
+
+	for i := 0; i < 10; i++ {
+		_, err := GetAll(dc, m)
+		var decodeErr *DecodeError
+		require.True(t, errors.As(err, &decodeErr))
+		require.Equal(t, "a", decodeErr.Key)
+	}
+}
+
+func Test_Upsert(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+	// without TEMPORAL_SDK_FLAG_7, workflow.UpsertMemo would write plaintext
+	env.ExecuteWorkflow(func(ctx workflow.Context) (string, error) {
+		if err := Upsert(ctx, codec.DefaultEncryptionCodec, map[string]interface{}{"city": "seattle"}); err != nil {
+			return "", err
+		}
+		m := workflow.GetInfo(ctx).Memo
+		if encoding := string(m.GetFields()["city"].GetMetadata()[converter.MetadataEncoding]); encoding != codec.MetadataEncodingEncrypted {
+			return "", &NotEncryptedError{Key: "city", Encoding: encoding}
+		}
+		return Get[string](codec.DefaultEncryptionCodec, m, "city")
+	})
+
+	require.NoError(t, env.GetWorkflowError())
+	var city string
+	require.NoError(t, env.GetWorkflowResult(&city))
+	require.Equal(t, "seattle", city)
+}
+
+func Test_UpsertNotEncrypted(t *testing.T) {
+	testSuite := &testsuite.WorkflowTestSuite{}
+	env := testSuite.NewTestWorkflowEnvironment()
+	env.ExecuteWorkflow(func(ctx workflow.Context) error {
+		err := Upsert(ctx, converter.GetDefaultDataConverter(), map[string]interface{}{"city": "seattle"})
+		var notEncrypted *NotEncryptedError
+		if !errors.As(err, &notEncrypted) || notEncrypted.Key != "city" {
+			return fmt.Errorf("expected a NotEncryptedError, got %v", err)
+		}
+		if _, ok := workflow.GetInfo(ctx).Memo.GetFields()["city"]; ok {
+			return errors.New("plaintext memo was upserted")
+		}
+		return nil
+	})
+
+	require.NoError(t, env.GetWorkflowError())
+}
@@ -2,19 +2,45 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
 
 	"encrypted_memo"
 	"encrypted_memo/codec"
+	"encrypted_memo/internal/keyflags"
+	"encrypted_memo/memo"
 
-	commonpb "go.temporal.io/api/common/v1"
 	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/workflow"
 )
 
 func main() {
+	flag.Parse()
+
+	// Keys come from -keyring, -kms or -key-env, like for the worker.
+	keyProvider, err := keyflags.KeyProvider()
+	if err != nil {
+		log.Fatalln("Unable to load keys", err)
+	}
+	keyID, err := keyflags.KeyID(keyProvider)
+	if err != nil {
+		log.Fatalln("Unable to pick the encryption key", err)
+	}
+	options := codec.DataConverterOptions{KeyID: keyID, KeyProvider: keyProvider, Compress: true}
+	dataConverter := codec.NewEncryptionDataConverter(converter.GetDefaultDataConverter(), options)
+
 	// The client is a heavyweight object that should be created once per process.
 	c, err := client.Dial(client.Options{
-		DataConverter: codec.DefaultEncryptionCodec,
+		DataConverter: dataConverter,
+		// Encrypts error messages and stack traces, which often contain customer data.
+		FailureConverter: codec.NewFailureConverter(dataConverter),
+		// Use a ContextPropagator so the tenant set in the context below picks the
+		// encryption key for the workflow and its activities too.
+		// The propagated headers are encrypted too, they'd otherwise show the tenant in every event.
+		ContextPropagators: []workflow.ContextPropagator{
+			codec.NewEncryptedContextPropagator(codec.NewContextPropagator(), codec.NewCodec(options)),
+		},
 	})
 	if err != nil {
 		log.Fatalln("Unable to create client", err)
@@ -26,13 +52,16 @@ func main() {
 		TaskQueue: "encryption",
 	}
 
-	ctx := context.Background()
+	ctx := context.WithValue(context.Background(), codec.PropagatedValuesKey, codec.PropagatedValues{
+		TenantID: "tenant1",
+	})
 
 	// The workflow input "My Secret Friend" will be encrypted by the DataConverter before being sent to Temporal
+	var workflows *encryption.Workflows
 	we, err := c.ExecuteWorkflow(
 		ctx,
 		workflowOptions,
-		encryption.Workflow,
+		workflows.Workflow,
 		"My Secret Friend",
 	)
 	if err != nil {
@@ -49,34 +78,19 @@ func main() {
 	}
 	log.Println("Workflow result:", result)
 
-	resp, err := c.DescribeWorkflowExecution(context.Background(), we.GetID(), we.GetRunID())
+	// The DecodingClient decodes the memo, search attributes and other payloads of the description,
+	// with the codecs of the DataConverter.
+	decodingClient := codec.NewDecodingClient(c, codec.NewPayloadCodecs(options)...)
+	resp, err := decodingClient.DescribeWorkflowExecution(context.Background(), we.GetID(), we.GetRunID())
 	if err != nil {
 		log.Fatalln("Unable to describe workflow", err)
 	}
 
 	// read memo
-	memo, err := decodeMemo(resp.GetWorkflowExecutionInfo().GetMemo())
+	memoValues, err := memo.GetAll(converter.GetDefaultDataConverter(), resp.GetWorkflowExecutionInfo().GetMemo())
 	if err != nil {
 		log.Fatalln("Unable to decode workflow memo", err)
 	}
 
-	log.Println("Workflow memo:", memo)
-}
-
-func decodeMemo(memo *commonpb.Memo) (map[string]interface{}, error) {
-	fields := memo.GetFields()
-	if len(fields) == 0 {
-		return map[string]interface{}{}, nil
-	}
-
-	decoded := make(map[string]interface{}, len(fields))
-	for key, payload := range fields {
-		var value interface{}
-		if err := codec.DefaultEncryptionCodec.FromPayload(payload, &value); err != nil {
-			return nil, err
-		}
-		decoded[key] = value
-	}
-
-	return decoded, nil
+	log.Println("Workflow memo:", memoValues)
 }
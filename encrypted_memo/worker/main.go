@@ -1,28 +1,60 @@
 package main
 
 import (
+	"flag"
 	"log"
 
 	"encrypted_memo"
 	"encrypted_memo/codec"
+	"encrypted_memo/internal/keyflags"
 
 	"go.temporal.io/sdk/client"
+	"go.temporal.io/sdk/converter"
+	"go.temporal.io/sdk/interceptor"
 	"go.temporal.io/sdk/worker"
+	"go.temporal.io/sdk/workflow"
 )
 
 func main() {
+	flag.Parse()
+
+	// Keys come from -keyring, -kms or -key-env, without them a hard coded test key is used.
+	keyProvider, err := keyflags.KeyProvider()
+	if err != nil {
+		log.Fatalln("Unable to load keys", err)
+	}
+	keyID, err := keyflags.KeyID(keyProvider)
+	if err != nil {
+		log.Fatalln("Unable to pick the encryption key", err)
+	}
+	options := codec.DataConverterOptions{KeyID: keyID, KeyProvider: keyProvider, Compress: true}
+	dataConverter := codec.NewEncryptionDataConverter(converter.GetDefaultDataConverter(), options)
+
 	// The client and worker are heavyweight objects that should be created once per process.
 	c, err := client.Dial(client.Options{
-		DataConverter: codec.DefaultEncryptionCodec,
+		DataConverter: dataConverter,
+		// Encrypts error messages and stack traces, which often contain customer data.
+		FailureConverter: codec.NewFailureConverter(dataConverter),
+		// Propagates the tenant so each tenant's payloads are encrypted with their own key.
+		// The propagated headers are encrypted too, with the keys of the DataConverter, they'd otherwise show the
+		// tenant in every event.
+		ContextPropagators: []workflow.ContextPropagator{
+			codec.NewEncryptedContextPropagator(codec.NewContextPropagator(), codec.NewCodec(options)),
+		},
 	})
 	if err != nil {
 		log.Fatalln("Unable to create client", err)
 	}
 	defer c.Close()
 
-	w := worker.New(c, "encryption", worker.Options{})
+	w := worker.New(c, "encryption", worker.Options{
+		// Fails the workflow task rather than write a plaintext memo or PII search attribute.
+		Interceptors: []interceptor.WorkerInterceptor{
+			codec.NewGuardInterceptor(codec.GuardOptions{}),
+		},
+	})
 
-	w.RegisterWorkflow(encryption.Workflow)
+	w.RegisterWorkflow((&encryption.Workflows{DataConverter: dataConverter}).Workflow)
 	w.RegisterActivity(encryption.Activity)
 
 	err = w.Run(worker.InterruptCh())
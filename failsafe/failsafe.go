@@ -0,0 +1,83 @@
+// Package failsafe decodes payloads one by one through a chain of codecs, swapping the ones that fail for a
+// placeholder, it's shared by the codec servers of the samples.
+package failsafe
+
+import (
+	"encoding/json"
+
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+)
+
+// Codec decodes payloads one by one through a chain of codecs, swapping the ones that fail for the payload
+// returned by its placeholder func. One bad payload would otherwise fail a whole codec server request and hide the
+// rest of the history.
+//
+// The codecs are in the order converter.NewPayloadCodecHTTPHandler takes them, Encode fails as a whole.
+type Codec struct {
+	codecs      []converter.PayloadCodec
+	placeholder func(payload *commonpb.Payload, err error) *commonpb.Payload
+	// OnError is called with each payload that failed to decode, e.g. to log it
+	OnError func(payload *commonpb.Payload, err error)
+}
+
+var _ converter.PayloadCodec = (*Codec)(nil) // ensure interface is implemented
+
+// New returns a Codec for the chain of codecs, placeholder renders the payload that replaces one that failed
+func New(placeholder func(payload *commonpb.Payload, err error) *commonpb.Payload, codecs ...converter.PayloadCodec) *Codec {
+	return &Codec{codecs: codecs, placeholder: placeholder}
+}
+
+// Encode implements converter.PayloadCodec.Encode.
+func (c *Codec) Encode(payloads []*commonpb.Payload) ([]*commonpb.Payload, error) {
+	var err error
+	for i := len(c.codecs) - 1; i >= 0; i-- {
+		if payloads, err = c.codecs[i].Encode(payloads); err != nil {
+			return payloads, err
+		}
+	}
+
+	return payloads, nil
+}
+
+// Decode implements converter.PayloadCodec.Decode, it never fails.
+func (c *Codec) Decode(payloads []*commonpb.Payload) ([]*commonpb.Payload, error) {
+	result := make([]*commonpb.Payload, len(payloads))
+	for i, p := range payloads {
+		decoded := []*commonpb.Payload{p}
+		var err error
+		for _, codec := range c.codecs {
+			if decoded, err = codec.Decode(decoded); err != nil {
+				break
+			}
+		}
+		if err != nil {
+			if c.OnError != nil {
+				c.OnError(p, err)
+			}
+			result[i] = c.placeholder(p, err)
+			continue
+		}
+
+		result[i] = decoded[0]
+	}
+
+	return result, nil
+}
+
+// Placeholder renders body as a json payload marked with the metadataKey metadata set to value.
+// The Temporal UI and CLI display json payloads as is, without a codec.
+func Placeholder(body interface{}, metadataKey, value string) (*commonpb.Payload, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &commonpb.Payload{
+		Metadata: map[string][]byte{
+			converter.MetadataEncoding: []byte(converter.MetadataEncodingJSON),
+			metadataKey:                []byte(value),
+		},
+		Data: data,
+	}, nil
+}
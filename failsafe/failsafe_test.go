@@ -0,0 +1,55 @@
+package failsafe
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	commonpb "go.temporal.io/api/common/v1"
+	"go.temporal.io/sdk/converter"
+)
+
+// failingCodec fails to decode payloads with the "fail" metadata
+type failingCodec struct{}
+
+func (failingCodec) Encode(payloads []*commonpb.Payload) ([]*commonpb.Payload, error) {
+	return payloads, nil
+}
+
+func (failingCodec) Decode(payloads []*commonpb.Payload) ([]*commonpb.Payload, error) {
+	for _, p := range payloads {
+		if _, ok := p.GetMetadata()["fail"]; ok {
+			return nil, errors.New("bad payload")
+		}
+	}
+	return payloads, nil
+}
+
+func Test_Codec(t *testing.T) {
+	dc := converter.GetDefaultDataConverter()
+	good, err := dc.ToPayload("good")
+	require.NoError(t, err)
+	bad, err := dc.ToPayload("bad")
+	require.NoError(t, err)
+	bad.Metadata["fail"] = []byte("true")
+
+	var failed []error
+	codec := New(func(p *commonpb.Payload, err error) *commonpb.Payload {
+		placeholder, _ := Placeholder(map[string]string{"error": err.Error()}, "decode-error", "failed")
+		return placeholder
+	}, failingCodec{})
+	codec.OnError = func(_ *commonpb.Payload, err error) { failed = append(failed, err) }
+
+	decoded, err := codec.Decode([]*commonpb.Payload{good, bad})
+	require.NoError(t, err)
+	require.Len(t, failed, 1)
+
+	var s string
+	require.NoError(t, dc.FromPayload(decoded[0], &s))
+	require.Equal(t, "good", s)
+
+	require.Equal(t, "failed", string(decoded[1].Metadata["decode-error"]))
+	var body map[string]string
+	require.NoError(t, dc.FromPayload(decoded[1], &body))
+	require.Equal(t, map[string]string{"error": "bad payload"}, body)
+}